@@ -0,0 +1,260 @@
+// Package agent implements the probe agent: a server which reports the
+// pod it runs in and the address its clients connect from, over HTTP,
+// TCP and UDP, and a client which probes such servers and reports the
+// outcome as JSON.
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Protocols supported by the agent.
+const (
+	HTTP = "http"
+	TCP  = "tcp"
+	UDP  = "udp"
+)
+
+// Default ports the agent listens on.
+const (
+	DefaultHTTPPort = 80
+	DefaultTCPPort  = 8080
+	DefaultUDPPort  = 8081
+)
+
+// DefaultTimeout is the time a target is given to reply to a probe.
+const DefaultTimeout = 5 * time.Second
+
+// Outcomes of a probe. A probe which is dropped times out, and one
+// which is rejected is refused.
+const (
+	OutcomeSuccess    = "success"
+	OutcomeTimeout    = "timeout"
+	OutcomeRefused    = "refused"
+	OutcomeDNSFailure = "dns-failure"
+	OutcomeError      = "error"
+)
+
+// Response is what the agent replies to every request or connection.
+type Response struct {
+	Protocol  string `json:"protocol"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace,omitempty"`
+	ClientIP  string `json:"clientIP"`
+}
+
+// Result is the outcome of a probe. Connected is set when the agent at
+// the target replied, in which case Response is its reply. Outcome
+// classifies the probe, as one of the Outcome constants.
+type Result struct {
+	Protocol       string    `json:"protocol"`
+	Target         string    `json:"target"`
+	Connected      bool      `json:"connected"`
+	Outcome        string    `json:"outcome,omitempty"`
+	Response       *Response `json:"response,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMillis int64     `json:"durationMillis"`
+}
+
+// Server replies to every request or connection with a Response.
+type Server struct {
+	Pod       string
+	Namespace string
+}
+
+func (s *Server) response(protocol, remoteAddr string) Response {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return Response{
+		Protocol:  protocol,
+		Pod:       s.Pod,
+		Namespace: s.Namespace,
+		ClientIP:  host,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.response(HTTP, r.RemoteAddr))
+}
+
+// ServeTCP writes a Response line to every connection accepted by the
+// listener, then closes it.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+			json.NewEncoder(conn).Encode(s.response(TCP, conn.RemoteAddr().String()))
+		}()
+	}
+}
+
+// ServeUDP replies with a Response to every datagram received.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 1500)
+	for {
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(s.response(UDP, addr.String()))
+		if err != nil {
+			return err
+		}
+		conn.WriteTo(data, addr)
+	}
+}
+
+// Probe connects to the agent at host and port with the protocol, and
+// returns the outcome within the timeout.
+func Probe(protocol, host string, port int, timeout time.Duration) Result {
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	result := Result{Protocol: protocol, Target: target}
+
+	start := time.Now()
+	var data []byte
+	var err error
+	switch protocol {
+	case HTTP:
+		data, err = probeHTTP(target, timeout)
+	case TCP:
+		data, err = probeTCP(target, timeout)
+	case UDP:
+		data, err = probeUDP(target, timeout)
+	default:
+		err = fmt.Errorf("unknown protocol %q", protocol)
+	}
+	result.DurationMillis = int64(time.Since(start) / time.Millisecond)
+
+	if err == nil {
+		response := &Response{}
+		if err = json.Unmarshal(data, response); err == nil {
+			result.Connected = true
+			result.Response = response
+		} else {
+			err = fmt.Errorf("unexpected reply %q: %v", data, err)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Outcome = outcome(err)
+	return result
+}
+
+// outcome classifies the error of a probe.
+func outcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	timeout := false
+	for e := err; e != nil; e = unwrap(e) {
+		switch e := e.(type) {
+		case *net.DNSError:
+			return OutcomeDNSFailure
+		case syscall.Errno:
+			if e == syscall.ECONNREFUSED || e == syscall.ECONNRESET || e == syscall.EHOSTUNREACH {
+				return OutcomeRefused
+			}
+		}
+		if netErr, ok := e.(net.Error); ok && netErr.Timeout() {
+			timeout = true
+		}
+	}
+	if timeout {
+		return OutcomeTimeout
+	}
+	return OutcomeError
+}
+
+// unwrap returns the error wrapped by an error of the net, net/url or
+// os packages, or nil. The agent is built with Go versions predating
+// errors.Unwrap.
+func unwrap(err error) error {
+	switch e := err.(type) {
+	case *url.Error:
+		return e.Err
+	case *net.OpError:
+		return e.Err
+	case *os.SyscallError:
+		return e.Err
+	}
+	return nil
+}
+
+func probeHTTP(target string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get("http://" + target + "/")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func probeTCP(target string, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", target, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	return bufio.NewReader(conn).ReadBytes('\n')
+}
+
+func probeUDP(target string, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", target, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte("probe\n")); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// ParseResult returns the Result printed by the probe command in its
+// output, ignoring any other line.
+func ParseResult(output string) (Result, error) {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		result := Result{}
+		if err := json.Unmarshal([]byte(line), &result); err == nil {
+			return result, nil
+		}
+	}
+	return Result{}, fmt.Errorf("no probe result found in output %q", output)
+}
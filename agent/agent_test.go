@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	s := &Server{Pod: "w1-abcde", Namespace: "ns1"}
+
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+	_, httpPort, _ := net.SplitHostPort(httpServer.Listener.Addr().String())
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer tcpListener.Close()
+	go s.ServeTCP(tcpListener)
+	_, tcpPort, _ := net.SplitHostPort(tcpListener.Addr().String())
+
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer udpConn.Close()
+	go s.ServeUDP(udpConn)
+	_, udpPort, _ := net.SplitHostPort(udpConn.LocalAddr().String())
+
+	for protocol, port := range map[string]string{HTTP: httpPort, TCP: tcpPort, UDP: udpPort} {
+		p, _ := strconv.Atoi(port)
+		result := Probe(protocol, "127.0.0.1", p, time.Second)
+		if !result.Connected || result.Error != "" || result.Outcome != OutcomeSuccess {
+			t.Errorf("%v: not connected: %+v", protocol, result)
+			continue
+		}
+		want := Response{Protocol: protocol, Pod: "w1-abcde", Namespace: "ns1", ClientIP: "127.0.0.1"}
+		if *result.Response != want {
+			t.Errorf("%v: response = %+v, want %+v", protocol, *result.Response, want)
+		}
+		if result.Target != "127.0.0.1:"+port {
+			t.Errorf("%v: target = %v", protocol, result.Target)
+		}
+	}
+}
+
+func TestProbeClosedPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+
+	p, _ := strconv.Atoi(port)
+	for _, protocol := range []string{HTTP, TCP} {
+		result := Probe(protocol, "127.0.0.1", p, time.Second)
+		if result.Connected || result.Response != nil || result.Error == "" {
+			t.Errorf("%v: connected to a closed port: %+v", protocol, result)
+		}
+		if result.Outcome != OutcomeRefused {
+			t.Errorf("%v: outcome = %v, want %v", protocol, result.Outcome, OutcomeRefused)
+		}
+	}
+	if result := Probe("sctp", "127.0.0.1", p, time.Second); result.Connected || result.Error == "" || result.Outcome != OutcomeError {
+		t.Errorf("probed with an unknown protocol: %+v", result)
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		// Accept connections but never reply.
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	p, _ := strconv.Atoi(port)
+	for _, protocol := range []string{HTTP, TCP} {
+		result := Probe(protocol, "127.0.0.1", p, 200*time.Millisecond)
+		if result.Connected || result.Outcome != OutcomeTimeout {
+			t.Errorf("%v: outcome = %v, want %v: %+v", protocol, result.Outcome, OutcomeTimeout, result)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	dnsErr := &url.Error{Op: "Get", URL: "http://w2.ns2/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "w2.ns2"}}}
+	if got := outcome(dnsErr); got != OutcomeDNSFailure {
+		t.Errorf("outcome = %v, want %v", got, OutcomeDNSFailure)
+	}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}
+	if got := outcome(refused); got != OutcomeRefused {
+		t.Errorf("outcome = %v, want %v", got, OutcomeRefused)
+	}
+	if got := outcome(fmt.Errorf("unexpected status 500")); got != OutcomeError {
+		t.Errorf("outcome = %v, want %v", got, OutcomeError)
+	}
+	if got := outcome(nil); got != OutcomeSuccess {
+		t.Errorf("outcome = %v, want %v", got, OutcomeSuccess)
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	output := "warning: something\r\n" +
+		`{"protocol":"http","target":"w1.ns1:80","connected":true,"response":{"protocol":"http","pod":"w1-abcde","clientIP":"10.42.0.5"},"durationMillis":3}` + "\r\n"
+	result, err := ParseResult(output)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !result.Connected || result.Response.Pod != "w1-abcde" || result.Response.ClientIP != "10.42.0.5" || result.DurationMillis != 3 {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := ParseResult("curl: (28) Connection timed out\n"); err == nil {
+		t.Errorf("parsed a result from output without one")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func cleanupCommand(args []string) error {
+	flags := pflag.NewFlagSet("cleanup", pflag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only list the resources that would be deleted")
+	flags.Parse(args)
+
+	rs, err := framework.NewRancherServerFromEnvVars()
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	projects, err := rs.ListTestProjects()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i := range projects {
+		p := &projects[i]
+		if *dryRun {
+			fmt.Printf("would delete project %v (%v)\n", p.Name, p.ID)
+			continue
+		}
+		if err := rs.ManagementClient.Project.Delete(p); err != nil {
+			logrus.Errorf("error deleting project %v (%v): %v", p.Name, p.ID, err)
+			failed++
+			continue
+		}
+		fmt.Printf("deleted project %v (%v)\n", p.Name, p.ID)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %v of %v projects", failed, len(projects))
+	}
+
+	users, err := rs.ListTestUsers()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		u := &users[i]
+		if *dryRun {
+			fmt.Printf("would delete user %v (%v)\n", u.Username, u.ID)
+			continue
+		}
+		if err := rs.ManagementClient.User.Delete(u); err != nil {
+			logrus.Errorf("error deleting user %v (%v): %v", u.Username, u.ID, err)
+			failed++
+			continue
+		}
+		fmt.Printf("deleted user %v (%v)\n", u.Username, u.ID)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %v of %v users", failed, len(users))
+	}
+	return nil
+}
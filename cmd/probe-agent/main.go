@@ -0,0 +1,126 @@
+// Command probe-agent runs in the probe workloads of the tests. "serve"
+// reports the pod name, namespace and client address of every request
+// over HTTP, TCP and UDP; "probe" probes another agent and prints the
+// result as JSON.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/test-network-policy/agent"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n"+
+		"  %[1]v serve [--http-port n] [--tcp-port n] [--udp-port n]\n"+
+		"  %[1]v probe --host host [--protocol http|tcp|udp] [--port n] [--timeout d]\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = serveCommand(os.Args[2:])
+	case "probe":
+		err = probeCommand(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		logrus.Fatalf("%v: %v", os.Args[1], err)
+	}
+}
+
+func serveCommand(args []string) error {
+	flags := pflag.NewFlagSet("serve", pflag.ExitOnError)
+	httpPort := flags.Int("http-port", agent.DefaultHTTPPort, "port of the HTTP listener, 0 to disable it")
+	tcpPort := flags.Int("tcp-port", agent.DefaultTCPPort, "port of the TCP listener, 0 to disable it")
+	udpPort := flags.Int("udp-port", agent.DefaultUDPPort, "port of the UDP listener, 0 to disable it")
+	flags.Parse(args)
+
+	s := &agent.Server{
+		Pod:       os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+	}
+	if s.Pod == "" {
+		s.Pod, _ = os.Hostname()
+	}
+	if s.Namespace == "" {
+		if data, err := ioutil.ReadFile(namespaceFile); err == nil {
+			s.Namespace = strings.TrimSpace(string(data))
+		}
+	}
+
+	errs := make(chan error, 3)
+	if *httpPort != 0 {
+		go func() {
+			errs <- http.ListenAndServe(":"+strconv.Itoa(*httpPort), s)
+		}()
+	}
+	if *tcpPort != 0 {
+		l, err := net.Listen("tcp", ":"+strconv.Itoa(*tcpPort))
+		if err != nil {
+			return err
+		}
+		go func() {
+			errs <- s.ServeTCP(l)
+		}()
+	}
+	if *udpPort != 0 {
+		conn, err := net.ListenPacket("udp", ":"+strconv.Itoa(*udpPort))
+		if err != nil {
+			return err
+		}
+		go func() {
+			errs <- s.ServeUDP(conn)
+		}()
+	}
+	logrus.Infof("serving pod %v/%v on HTTP %v, TCP %v and UDP %v", s.Namespace, s.Pod, *httpPort, *tcpPort, *udpPort)
+	return <-errs
+}
+
+// probeCommand prints the result of the probe, and exits successfully
+// whether or not the target could be reached: the result tells.
+func probeCommand(args []string) error {
+	flags := pflag.NewFlagSet("probe", pflag.ExitOnError)
+	protocol := flags.String("protocol", agent.HTTP, "protocol of the probe: http, tcp or udp")
+	host := flags.String("host", "", "host name or IP address of the target")
+	port := flags.Int("port", 0, "port of the target, the default port of the protocol when not set")
+	timeout := flags.Duration("timeout", agent.DefaultTimeout, "time the target is given to reply")
+	flags.Parse(args)
+
+	if *host == "" {
+		return fmt.Errorf("--host is required")
+	}
+	if *port == 0 {
+		switch *protocol {
+		case agent.HTTP:
+			*port = agent.DefaultHTTPPort
+		case agent.TCP:
+			*port = agent.DefaultTCPPort
+		case agent.UDP:
+			*port = agent.DefaultUDPPort
+		default:
+			return fmt.Errorf("unknown protocol %q", *protocol)
+		}
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(agent.Probe(*protocol, *host, *port, *timeout))
+}
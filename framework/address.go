@@ -0,0 +1,86 @@
+package framework
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Via is the kind of address a destination is probed through, which
+// tells the policies of the CNI apart from service routing: a pod IP
+// only goes through the CNI, a ClusterIP also goes through kube-proxy,
+// and a DNS name also needs the cluster DNS.
+type Via string
+
+const (
+	// ViaPodIP probes the IP address of the destination pod.
+	ViaPodIP Via = "pod-ip"
+	// ViaClusterIP probes the ClusterIP of the service of the
+	// destination workload.
+	ViaClusterIP Via = "cluster-ip"
+	// ViaDNS probes the DNS name of the service of the destination
+	// workload. It is the default.
+	ViaDNS Via = "dns"
+)
+
+// Vias lists all the kinds of address, from the most direct one.
+var Vias = []Via{ViaPodIP, ViaClusterIP, ViaDNS}
+
+// ParseVia returns the kind of address with the name.
+func ParseVia(name string) (Via, error) {
+	for _, via := range Vias {
+		if string(via) == name {
+			return via, nil
+		}
+	}
+	return "", fmt.Errorf("unknown address kind %q, expected one of %v", name, Vias)
+}
+
+// Address returns the address of the endpoint of the kind, or an
+// empty string if it was not resolved.
+func (e Endpoint) Address(via Via) string {
+	switch via {
+	case ViaPodIP:
+		return e.PodIP
+	case ViaClusterIP:
+		return e.ClusterIP
+	default:
+		return e.Host()
+	}
+}
+
+// ResolveEndpoint returns the endpoint with the IP address and the node
+// of its pod, and the ClusterIP of the service of its workload.
+func (c *KubeClient) ResolveEndpoint(e Endpoint) (Endpoint, error) {
+	pod := &corev1.Pod{}
+	if err := c.GetObject(PodResource, e.Namespace, e.Pod, pod); err != nil {
+		return e, fmt.Errorf("error fetching pod %v/%v: %v", e.Namespace, e.Pod, err)
+	}
+	if pod.Status.PodIP == "" {
+		return e, fmt.Errorf("pod %v/%v has no IP address", e.Namespace, e.Pod)
+	}
+	e.PodIP = pod.Status.PodIP
+	e.Node = pod.Spec.NodeName
+
+	service := &corev1.Service{}
+	if err := c.GetObject(ServiceResource, e.Namespace, e.Workload, service); err != nil {
+		return e, fmt.Errorf("error fetching service %v/%v: %v", e.Namespace, e.Workload, err)
+	}
+	if service.Spec.ClusterIP == "" || service.Spec.ClusterIP == corev1.ClusterIPNone {
+		return e, fmt.Errorf("service %v/%v has no ClusterIP", e.Namespace, e.Workload)
+	}
+	e.ClusterIP = service.Spec.ClusterIP
+	return e, nil
+}
+
+// ResolveEndpoints returns the endpoints resolved with ResolveEndpoint.
+func (c *KubeClient) ResolveEndpoints(endpoints []Endpoint) ([]Endpoint, error) {
+	resolved := make([]Endpoint, len(endpoints))
+	for i, e := range endpoints {
+		var err error
+		if resolved[i], err = c.ResolveEndpoint(e); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
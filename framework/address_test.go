@@ -0,0 +1,65 @@
+package framework
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestResolveEndpoint(t *testing.T) {
+	pod := `{"kind":"Pod","apiVersion":"v1","metadata":{"name":"w1-abcde","namespace":"ns1"},"status":{"podIP":"10.42.0.5"}}`
+	service := `{"kind":"Service","apiVersion":"v1","metadata":{"name":"w1","namespace":"ns1"},"spec":{"clusterIP":"10.43.12.34"}}`
+	headless := `{"kind":"Service","apiVersion":"v1","metadata":{"name":"w1","namespace":"ns1"},"spec":{"clusterIP":"None"}}`
+
+	for _, test := range []struct {
+		service string
+		ok      bool
+	}{
+		{service, true},
+		{headless, false},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/v1/namespaces/ns1/pods/w1-abcde":
+				w.Write([]byte(pod))
+			case "/api/v1/namespaces/ns1/services/w1":
+				w.Write([]byte(test.service))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		c := NewKubeClient(&rest.Config{Host: server.URL})
+		e, err := c.ResolveEndpoint(Endpoint{Workload: "w1", Namespace: "ns1", Pod: "w1-abcde"})
+		server.Close()
+
+		if !test.ok {
+			if err == nil {
+				t.Errorf("resolved an endpoint behind a headless service: %+v", e)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		for via, want := range map[Via]string{ViaPodIP: "10.42.0.5", ViaClusterIP: "10.43.12.34", ViaDNS: "w1.ns1"} {
+			if got := e.Address(via); got != want {
+				t.Errorf("address via %v = %q, want %q", via, got, want)
+			}
+		}
+	}
+}
+
+func TestParseVia(t *testing.T) {
+	for _, via := range Vias {
+		if got, err := ParseVia(string(via)); err != nil || got != via {
+			t.Errorf("ParseVia(%q) = %v, %v", via, got, err)
+		}
+	}
+	if _, err := ParseVia("node-ip"); err == nil {
+		t.Errorf("parsed an unknown address kind")
+	}
+}
@@ -0,0 +1,193 @@
+package framework
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	normantypes "github.com/rancher/norman/types"
+	rclusterv3 "github.com/rancher/types/client/cluster/v3"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+)
+
+const maxArtifactNameLength = 100
+
+var unsafeArtifactChars = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// ArtifactName returns a file name for the spec text.
+func ArtifactName(spec string) string {
+	name := strings.Trim(unsafeArtifactChars.ReplaceAllString(strings.ToLower(spec), "-"), "-.")
+	if len(name) > maxArtifactNameLength {
+		name = strings.TrimRight(name[:maxArtifactNameLength], "-.")
+	}
+	if name == "" {
+		return "spec"
+	}
+	return name
+}
+
+// ArtifactCollector captures the state of the projects of a failed
+// spec before they are deleted, so that the failure can be inspected
+// after the run.
+type ArtifactCollector struct {
+	Server *RancherServer
+	Dir    string
+}
+
+// NewArtifactCollector returns an ArtifactCollector writing into dir.
+func NewArtifactCollector(rs *RancherServer, dir string) *ArtifactCollector {
+	return &ArtifactCollector{
+		Server: rs,
+		Dir:    dir,
+	}
+}
+
+// Collect writes the YAML of the projects, their project network
+// policies, namespaces, workloads, pods, network policies and events,
+// the PolicyReport of the projects and the exec transcripts, into a
+// directory named after the spec under Dir. It carries on past errors,
+// which are also written to errors.txt in the directory, and returns
+// the directory.
+func (c *ArtifactCollector) Collect(spec string, projectIDs []string, transcripts []ExecTranscript) (string, error) {
+	w := &artifactWriter{dir: filepath.Join(c.Dir, ArtifactName(spec))}
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return w.dir, fmt.Errorf("error creating artifact directory %v: %v", w.dir, err)
+	}
+
+	for _, id := range projectIDs {
+		c.collectProject(w, id)
+	}
+
+	if len(projectIDs) > 0 {
+		if report, err := c.Server.ProjectPolicyReport(projectIDs); err != nil {
+			w.fail(err)
+		} else {
+			w.fail(ioutil.WriteFile(filepath.Join(w.dir, "networkpolicies.txt"), []byte(report.String()), 0644))
+		}
+	}
+
+	if len(transcripts) > 0 {
+		f, err := os.Create(filepath.Join(w.dir, "transcripts.txt"))
+		if err != nil {
+			w.fail(err)
+		} else {
+			w.fail(WriteTranscripts(f, transcripts))
+			w.fail(f.Close())
+		}
+	}
+
+	return w.dir, w.finish()
+}
+
+func (c *ArtifactCollector) collectProject(w *artifactWriter, projectID string) {
+	rs := c.Server
+	project, err := rs.ManagementClient.Project.ByID(projectID)
+	if err != nil {
+		w.fail(fmt.Errorf("error fetching project %v: %v", projectID, err))
+		return
+	}
+	dir := ArtifactName(project.Name)
+	w.writeYAML(filepath.Join(dir, "project.yaml"), project)
+
+	pnps, err := rs.ManagementClient.ProjectNetworkPolicy.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{"projectId": projectID},
+	})
+	if err != nil {
+		w.fail(fmt.Errorf("error listing project network policies of %v: %v", projectID, err))
+	} else {
+		w.writeYAML(filepath.Join(dir, "projectnetworkpolicies.yaml"), pnps.Data)
+	}
+
+	client, err := rs.GetProjectClientByID(projectID)
+	if err != nil {
+		w.fail(fmt.Errorf("error creating client for project %v: %v", projectID, err))
+		return
+	}
+
+	namespaces, err := rs.DefaultClusterClient.Namespace.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{"projectId": projectID},
+	})
+	if err != nil {
+		w.fail(fmt.Errorf("error listing namespaces of project %v: %v", projectID, err))
+		return
+	}
+	for i := range namespaces.Data {
+		ns := &namespaces.Data[i]
+		c.collectNamespace(w, client, filepath.Join(dir, ns.Name), ns)
+	}
+}
+
+func (c *ArtifactCollector) collectNamespace(w *artifactWriter, client *rprojectv3.Client, dir string, ns *rclusterv3.Namespace) {
+	rs := c.Server
+	w.writeYAML(filepath.Join(dir, "namespace.yaml"), ns)
+
+	opts := &normantypes.ListOpts{
+		Filters: map[string]interface{}{"namespaceId": ns.Name},
+	}
+	if workloads, err := client.Workload.List(opts); err != nil {
+		w.fail(fmt.Errorf("error listing workloads in namespace %v: %v", ns.Name, err))
+	} else {
+		w.writeYAML(filepath.Join(dir, "workloads.yaml"), workloads.Data)
+	}
+	if pods, err := client.Pod.List(opts); err != nil {
+		w.fail(fmt.Errorf("error listing pods in namespace %v: %v", ns.Name, err))
+	} else {
+		w.writeYAML(filepath.Join(dir, "pods.yaml"), pods.Data)
+	}
+	if nps, err := rs.ListNetworkPolicies(ns.Name); err != nil {
+		w.fail(err)
+	} else {
+		w.writeYAML(filepath.Join(dir, "networkpolicies.yaml"), nps.Items)
+	}
+	if events, err := rs.ListEvents(ns.Name); err != nil {
+		w.fail(err)
+	} else {
+		w.writeYAML(filepath.Join(dir, "events.yaml"), events.Items)
+	}
+}
+
+// artifactWriter writes artifacts under dir and collects the errors
+// met along the way.
+type artifactWriter struct {
+	dir  string
+	errs []error
+}
+
+func (w *artifactWriter) fail(err error) {
+	if err != nil {
+		w.errs = append(w.errs, err)
+	}
+}
+
+func (w *artifactWriter) writeYAML(name string, obj interface{}) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		w.fail(fmt.Errorf("error marshalling %v: %v", name, err))
+		return
+	}
+	filename := filepath.Join(w.dir, name)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		w.fail(err)
+		return
+	}
+	w.fail(ioutil.WriteFile(filename, data, 0644))
+}
+
+func (w *artifactWriter) finish() error {
+	if len(w.errs) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, err := range w.errs {
+		msgs = append(msgs, err.Error())
+	}
+	msg := strings.Join(msgs, "\n")
+	if err := ioutil.WriteFile(filepath.Join(w.dir, "errors.txt"), []byte(msg+"\n"), 0644); err != nil {
+		return fmt.Errorf("%v\n%v", msg, err)
+	}
+	return fmt.Errorf("error collecting artifacts:\n%v", msg)
+}
@@ -0,0 +1,39 @@
+package framework
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArtifactName(t *testing.T) {
+	tests := map[string]string{
+		"ProjectIsolation projects should be isolated": "projectisolation-projects-should-be-isolated",
+		"Scenarios cross-project-allow [a/b -> c/d]":   "scenarios-cross-project-allow-a-b-c-d",
+		"  ":                     "spec",
+		strings.Repeat("a", 200): strings.Repeat("a", maxArtifactNameLength),
+	}
+	for spec, expected := range tests {
+		if name := ArtifactName(spec); name != expected {
+			t.Errorf("ArtifactName(%q) = %q, expected %q", spec, name, expected)
+		}
+	}
+}
+
+func TestWriteTranscripts(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteTranscripts(buf, []ExecTranscript{
+		{Start: time.Now(), Namespace: "ns1", Pod: "w1-abc", Container: "w1", Command: "curl http://w2.ns2", Output: "w2-def"},
+		{Start: time.Now(), Namespace: "ns1", Pod: "w1-abc", Container: "w1", Command: "curl http://w3.ns3", Err: fmt.Errorf("boom")},
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, want := range []string{"ns1/w1-abc/w1", "$ curl http://w2.ns2\nw2-def", "error: boom"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected transcripts to contain %q:\n%v", want, buf)
+		}
+	}
+}
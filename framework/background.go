@@ -0,0 +1,151 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Violation is a window of time during which a pair was
+// observed in a state other than the expected one.
+type Violation struct {
+	Expectation Expectation
+	Start       time.Time
+	End         time.Time
+	Probes      int
+	Outcome     ProbeOutcome
+	Output      string
+}
+
+// Duration returns the time between the first and the last
+// probe of the violation window.
+func (v Violation) Duration() time.Duration {
+	return v.End.Sub(v.Start)
+}
+
+func (v Violation) String() string {
+	what := "denied traffic succeeded"
+	switch {
+	case !v.Outcome.Conclusive():
+		what = fmt.Sprintf("probes failed (%v)", v.Outcome)
+	case v.Expectation.Reachable:
+		what = "allowed traffic failed"
+	}
+	return fmt.Sprintf("%v: %v for %v (%v probes, from %v to %v)", v.Expectation.Pair, what,
+		v.Duration().Round(time.Millisecond), v.Probes,
+		v.Start.Format(time.RFC3339Nano), v.End.Format(time.RFC3339Nano))
+}
+
+// BackgroundProber probes a fixed set of expectations at a fixed
+// interval until stopped, recording every window in which a pair
+// was not in its expected state.
+type BackgroundProber struct {
+	prober       *Prober
+	expectations []Expectation
+	interval     time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu         sync.Mutex
+	rounds     int
+	open       map[int]*Violation
+	violations []Violation
+}
+
+// StartBackground starts probing the expectations every interval
+// in the background. Stop must be called to collect the violations.
+func (p *Prober) StartBackground(expectations []Expectation, interval time.Duration) *BackgroundProber {
+	b := newBackgroundProber(p, expectations, interval)
+	go b.run()
+	return b
+}
+
+func newBackgroundProber(p *Prober, expectations []Expectation, interval time.Duration) *BackgroundProber {
+	return &BackgroundProber{
+		prober:       p,
+		expectations: expectations,
+		interval:     interval,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+		open:         map[int]*Violation{},
+	}
+}
+
+func (b *BackgroundProber) run() {
+	defer close(b.done)
+
+	pairs := make([]Pair, len(b.expectations))
+	for i, e := range b.expectations {
+		pairs[i] = e.Pair
+	}
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		b.record(b.prober.ProbeAll(pairs), time.Now())
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record updates the violation windows with the results of one
+// round of probes, which are in the same order as the expectations.
+func (b *BackgroundProber) record(results []ProbeResult, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rounds++
+	for i, r := range results {
+		if b.expectations[i].Met(r) {
+			if v, ok := b.open[i]; ok {
+				b.violations = append(b.violations, *v)
+				delete(b.open, i)
+			}
+			continue
+		}
+
+		v, ok := b.open[i]
+		if !ok {
+			v = &Violation{Expectation: b.expectations[i], Start: at}
+			b.open[i] = v
+		}
+		v.End = at
+		v.Probes++
+		v.Outcome = r.Outcome
+		v.Output = r.Output
+	}
+}
+
+// Rounds returns the number of rounds of probes run so far.
+func (b *BackgroundProber) Rounds() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rounds
+}
+
+// Stop stops probing and returns all the violations observed,
+// including the ones still open when stopped. It is safe to call
+// Stop more than once.
+func (b *BackgroundProber) Stop() []Violation {
+	b.stopOnce.Do(func() { close(b.stop) })
+	<-b.done
+	return b.collect()
+}
+
+func (b *BackgroundProber) collect() []Violation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := append([]Violation(nil), b.violations...)
+	for i := range b.expectations {
+		if v, ok := b.open[i]; ok {
+			result = append(result, *v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+// sameExpectation compares expectations field by field, since their
+// endpoints are not comparable.
+func sameExpectation(a, b Expectation) bool {
+	return a.Pair.String() == b.Pair.String() && a.Reachable == b.Reachable
+}
+
+func TestBackgroundProberRecord(t *testing.T) {
+	allowed := Expectation{Pair: Pair{From: Endpoint{Workload: "a"}, To: Endpoint{Workload: "b"}}, Reachable: true}
+	denied := Expectation{Pair: Pair{From: Endpoint{Workload: "a"}, To: Endpoint{Workload: "c"}}, Reachable: false}
+	b := newBackgroundProber(nil, []Expectation{allowed, denied}, time.Second)
+
+	t0 := time.Now()
+	rounds := [][]bool{
+		{true, false},
+		{false, false},
+		{false, true},
+		{true, false},
+		{true, true},
+	}
+	for i, round := range rounds {
+		b.record([]ProbeResult{
+			reachableResult(round[0]),
+			reachableResult(round[1]),
+		}, t0.Add(time.Duration(i)*time.Second))
+	}
+
+	violations := b.collect()
+	if len(violations) != 3 {
+		t.Fatalf("expected 3 violations, got %v: %v", len(violations), violations)
+	}
+
+	if v := violations[0]; !sameExpectation(v.Expectation, allowed) || v.Probes != 2 || v.Duration() != time.Second {
+		t.Errorf("unexpected allowed violation: %v", v)
+	}
+	if v := violations[1]; !sameExpectation(v.Expectation, denied) || v.Probes != 1 || !v.Start.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("unexpected denied violation: %v", v)
+	}
+	if v := violations[2]; !sameExpectation(v.Expectation, denied) || v.Probes != 1 || !v.Start.Equal(t0.Add(4*time.Second)) {
+		t.Errorf("expected the open denied violation last, got %v", v)
+	}
+	if b.Rounds() != len(rounds) {
+		t.Errorf("expected %v rounds, got %v", len(rounds), b.Rounds())
+	}
+}
+
+func TestBackgroundProberRecordInconclusive(t *testing.T) {
+	denied := Expectation{Pair: Pair{From: Endpoint{Workload: "a"}, To: Endpoint{Workload: "c"}}, Reachable: false}
+	b := newBackgroundProber(nil, []Expectation{denied}, time.Second)
+
+	t0 := time.Now()
+	b.record([]ProbeResult{{Outcome: Refused}}, t0)
+	b.record([]ProbeResult{{Outcome: DNSFailure}}, t0.Add(time.Second))
+
+	violations := b.collect()
+	if len(violations) != 1 || violations[0].Outcome != DNSFailure || !violations[0].Start.Equal(t0.Add(time.Second)) {
+		t.Errorf("expected the failed probe to be a violation, got %v", violations)
+	}
+}
+
+// reachableResult returns the result of a probe which succeeded, or
+// which timed out.
+func reachableResult(reachable bool) ProbeResult {
+	if reachable {
+		return ProbeResult{Reachable: true, Outcome: Success}
+	}
+	return ProbeResult{Outcome: Timeout}
+}
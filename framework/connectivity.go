@@ -0,0 +1,139 @@
+package framework
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/rancher/test-network-policy/policy"
+)
+
+// ProbeRecord is the reportable form of a ProbeResult.
+type ProbeRecord struct {
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Via       string  `json:"via,omitempty"`
+	FromNode  string  `json:"fromNode,omitempty"`
+	ToNode    string  `json:"toNode,omitempty"`
+	Reachable bool    `json:"reachable"`
+	Outcome   string  `json:"outcome,omitempty"`
+	Latency   float64 `json:"latencySeconds"`
+	Output    string  `json:"output,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// NewProbeRecord returns the reportable form of the result.
+func NewProbeRecord(r ProbeResult) ProbeRecord {
+	record := ProbeRecord{
+		From:      r.Pair.From.String(),
+		To:        r.Pair.To.String(),
+		Via:       string(r.Pair.Via),
+		FromNode:  r.Pair.From.Node,
+		ToNode:    r.Pair.To.Node,
+		Reachable: r.Reachable,
+		Outcome:   string(r.Outcome),
+		Latency:   r.Latency.Seconds(),
+		Output:    r.Output,
+	}
+	if r.Err != nil {
+		record.Error = r.Err.Error()
+	}
+	return record
+}
+
+// TopologyProject is a project and the namespaces of the
+// endpoints probed in it.
+type TopologyProject struct {
+	Project    string              `json:"project"`
+	Namespaces []TopologyNamespace `json:"namespaces"`
+}
+
+// TopologyNamespace is a namespace and the workloads of the
+// endpoints probed in it.
+type TopologyNamespace struct {
+	Namespace string   `json:"namespace"`
+	Workloads []string `json:"workloads"`
+}
+
+// Connectivity is the reachability a spec expected and observed
+// between a set of endpoints.
+type Connectivity struct {
+	Name     string            `json:"name"`
+	Topology []TopologyProject `json:"topology,omitempty"`
+	Expected policy.Matrix     `json:"expected,omitempty"`
+	Observed policy.Matrix     `json:"observed"`
+	Probes   []ProbeRecord     `json:"probes,omitempty"`
+}
+
+// NewConnectivity returns the connectivity of a spec from its
+// expected matrix, which may be nil, and its probe results.
+func NewConnectivity(name string, expected policy.Matrix, results []ProbeResult) Connectivity {
+	c := Connectivity{
+		Name:     name,
+		Expected: expected,
+		Observed: policy.Matrix{},
+	}
+	var endpoints []Endpoint
+	for _, r := range results {
+		c.Observed.Set(r.Pair.From.String(), r.Pair.To.String(), r.Reachable)
+		c.Probes = append(c.Probes, NewProbeRecord(r))
+		endpoints = append(endpoints, r.Pair.From, r.Pair.To)
+	}
+	c.Topology = newTopology(endpoints)
+	return c
+}
+
+// newTopology groups the endpoints by project and namespace.
+func newTopology(endpoints []Endpoint) []TopologyProject {
+	sorted := append([]Endpoint(nil), endpoints...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name() < b.Name()
+	})
+
+	var topology []TopologyProject
+	for i, e := range sorted {
+		if i > 0 && sorted[i-1].String() == e.String() && sorted[i-1].Project == e.Project {
+			continue
+		}
+		if len(topology) == 0 || topology[len(topology)-1].Project != e.Project {
+			topology = append(topology, TopologyProject{Project: e.Project})
+		}
+		p := &topology[len(topology)-1]
+		if len(p.Namespaces) == 0 || p.Namespaces[len(p.Namespaces)-1].Namespace != e.Namespace {
+			p.Namespaces = append(p.Namespaces, TopologyNamespace{Namespace: e.Namespace})
+		}
+		ns := &p.Namespaces[len(p.Namespaces)-1]
+		ns.Workloads = append(ns.Workloads, e.Name())
+	}
+	return topology
+}
+
+// ConnectivityRecorder collects the connectivity of specs, keyed
+// by the full text of the spec. It is safe for concurrent use.
+type ConnectivityRecorder struct {
+	mu      sync.Mutex
+	records map[string][]Connectivity
+}
+
+// Record adds the connectivity to the spec.
+func (r *ConnectivityRecorder) Record(spec string, c Connectivity) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.records == nil {
+		r.records = map[string][]Connectivity{}
+	}
+	r.records[spec] = append(r.records[spec], c)
+}
+
+// Get returns the connectivity recorded for the spec.
+func (r *ConnectivityRecorder) Get(spec string) []Connectivity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Connectivity(nil), r.records[spec]...)
+}
@@ -0,0 +1,213 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultConvergenceInterval is the time between two
+	// rounds of probes while waiting for convergence.
+	DefaultConvergenceInterval = 1 * time.Second
+)
+
+// ConvergenceKind tells whether a pair converged towards
+// being blocked or towards being reachable.
+type ConvergenceKind string
+
+const (
+	TimeToEnforce ConvergenceKind = "time-to-enforce"
+	TimeToRelease ConvergenceKind = "time-to-release"
+)
+
+// Expectation is the reachability a pair is expected to
+// converge to after a policy or project change.
+type Expectation struct {
+	Pair      Pair
+	Reachable bool
+}
+
+// Kind returns the kind of convergence measured for the expectation.
+func (e Expectation) Kind() ConvergenceKind {
+	if e.Reachable {
+		return TimeToRelease
+	}
+	return TimeToEnforce
+}
+
+// Met tells whether the result is in the expected state: a success when
+// the pair is expected to be reachable, and blocked otherwise. A probe
+// which failed for another reason, as when the destination could not
+// be resolved, meets neither expectation.
+func (e Expectation) Met(r ProbeResult) bool {
+	if e.Reachable {
+		return r.Outcome == Success
+	}
+	return r.Outcome.Blocked()
+}
+
+// ConvergenceSample is the time it took a single pair to
+// reach its expected state.
+type ConvergenceSample struct {
+	Pair      Pair
+	Kind      ConvergenceKind
+	Duration  time.Duration
+	Converged bool
+}
+
+// WaitForConvergence repeatedly probes all the expectations until
+// each of them is observed in its expected state, or until timeout
+// expires. Durations are measured from start, which should be the
+// time the change was made.
+func (p *Prober) WaitForConvergence(start time.Time, expectations []Expectation, interval, timeout time.Duration) []ConvergenceSample {
+	samples := make([]ConvergenceSample, len(expectations))
+	for i, e := range expectations {
+		samples[i] = ConvergenceSample{Pair: e.Pair, Kind: e.Kind()}
+	}
+
+	deadline := start.Add(timeout)
+	for {
+		var pending []int
+		var pairs []Pair
+		for i, s := range samples {
+			if !s.Converged {
+				pending = append(pending, i)
+				pairs = append(pairs, s.Pair)
+			}
+		}
+		if len(pending) == 0 {
+			return samples
+		}
+
+		results := p.ProbeAll(pairs)
+		now := time.Now()
+		for j, r := range results {
+			i := pending[j]
+			if expectations[i].Met(r) {
+				samples[i].Converged = true
+				samples[i].Duration = now.Sub(start)
+			}
+		}
+
+		if now.After(deadline) {
+			for _, i := range pending {
+				if !samples[i].Converged {
+					samples[i].Duration = now.Sub(start)
+				}
+			}
+			return samples
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ConvergenceSummary holds the percentiles of all the samples
+// of a single kind.
+type ConvergenceSummary struct {
+	Kind   ConvergenceKind
+	Count  int
+	Failed int
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// ConvergenceRecorder collects convergence samples across specs.
+// It is safe for concurrent use.
+type ConvergenceRecorder struct {
+	mu      sync.Mutex
+	samples []ConvergenceSample
+}
+
+// Record adds samples to the recorder.
+func (r *ConvergenceRecorder) Record(samples ...ConvergenceSample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, samples...)
+}
+
+// Samples returns a copy of all the recorded samples.
+func (r *ConvergenceRecorder) Samples() []ConvergenceSample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ConvergenceSample(nil), r.samples...)
+}
+
+// Summary returns the percentiles of the converged samples of each kind.
+func (r *ConvergenceRecorder) Summary() []ConvergenceSummary {
+	return SummarizeConvergence(r.Samples())
+}
+
+// SummarizeConvergence returns the percentiles of the converged samples
+// of each kind.
+func SummarizeConvergence(samples []ConvergenceSample) []ConvergenceSummary {
+	byKind := map[ConvergenceKind]*ConvergenceSummary{}
+	durations := map[ConvergenceKind][]time.Duration{}
+	for _, s := range samples {
+		summary, ok := byKind[s.Kind]
+		if !ok {
+			summary = &ConvergenceSummary{Kind: s.Kind}
+			byKind[s.Kind] = summary
+		}
+		summary.Count++
+		if !s.Converged {
+			summary.Failed++
+			continue
+		}
+		durations[s.Kind] = append(durations[s.Kind], s.Duration)
+	}
+
+	var result []ConvergenceSummary
+	for _, kind := range []ConvergenceKind{TimeToEnforce, TimeToRelease} {
+		summary, ok := byKind[kind]
+		if !ok {
+			continue
+		}
+		d := durations[kind]
+		summary.P50 = Percentile(d, 50)
+		summary.P90 = Percentile(d, 90)
+		summary.P99 = Percentile(d, 99)
+		summary.Max = Percentile(d, 100)
+		result = append(result, *summary)
+	}
+	return result
+}
+
+// WriteSummary writes the convergence percentiles in a
+// human readable form.
+func (r *ConvergenceRecorder) WriteSummary(w io.Writer) {
+	summaries := r.Summary()
+	if len(summaries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%-16s %6s %6s %10s %10s %10s %10s\n", "KIND", "COUNT", "FAILED", "P50", "P90", "P99", "MAX")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%-16s %6d %6d %10v %10v %10v %10v\n", s.Kind, s.Count, s.Failed,
+			s.P50.Round(time.Millisecond), s.P90.Round(time.Millisecond),
+			s.P99.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+	}
+}
+
+// Percentile returns the p-th percentile of the durations using
+// the nearest-rank method.
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
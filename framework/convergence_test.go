@@ -0,0 +1,58 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 10; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+
+	tests := map[float64]time.Duration{
+		0:   1 * time.Second,
+		50:  5 * time.Second,
+		90:  9 * time.Second,
+		99:  10 * time.Second,
+		100: 10 * time.Second,
+	}
+	for p, expected := range tests {
+		if actual := Percentile(durations, p); actual != expected {
+			t.Errorf("p%v: expected %v, got %v", p, expected, actual)
+		}
+	}
+
+	if actual := Percentile(nil, 50); actual != 0 {
+		t.Errorf("expected 0 for no samples, got %v", actual)
+	}
+}
+
+func TestConvergenceRecorderSummary(t *testing.T) {
+	r := &ConvergenceRecorder{}
+	r.Record(
+		ConvergenceSample{Kind: TimeToEnforce, Duration: 2 * time.Second, Converged: true},
+		ConvergenceSample{Kind: TimeToEnforce, Duration: 4 * time.Second, Converged: true},
+		ConvergenceSample{Kind: TimeToEnforce, Duration: 60 * time.Second, Converged: false},
+		ConvergenceSample{Kind: TimeToRelease, Duration: 1 * time.Second, Converged: true},
+	)
+
+	summaries := r.Summary()
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 summaries, got %v", len(summaries))
+	}
+
+	enforce := summaries[0]
+	if enforce.Kind != TimeToEnforce || enforce.Count != 3 || enforce.Failed != 1 {
+		t.Errorf("unexpected enforce summary: %+v", enforce)
+	}
+	if enforce.P50 != 2*time.Second || enforce.Max != 4*time.Second {
+		t.Errorf("unexpected enforce percentiles: %+v", enforce)
+	}
+
+	release := summaries[1]
+	if release.Kind != TimeToRelease || release.Count != 1 || release.P99 != 1*time.Second {
+		t.Errorf("unexpected release summary: %+v", release)
+	}
+}
@@ -0,0 +1,338 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	normanclientbase "github.com/rancher/norman/clientbase"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultTokenTTL is the lifetime of the tokens minted for a run when
+// RANCHER_TOKEN_TTL is not set.
+const DefaultTokenTTL = 2 * time.Hour
+
+// tokenDescription describes the tokens minted for a run.
+const tokenDescription = "test-network-policy run"
+
+// tokenExpiryMargin is how long before its expiry a token is
+// considered expired, so that it does not expire during a request.
+const tokenExpiryMargin = 30 * time.Second
+
+// Credentials authenticate the requests to a Rancher server, with
+// either an API key pair, a bearer token, or a username and password
+// which Login exchanges for a token. They are used by the norman
+// clients, the Kubernetes clients and the exec websockets alike.
+type Credentials struct {
+	AccessKey string
+	SecretKey string
+	TokenKey  string
+
+	Username string
+	Password string
+
+	// ClusterTokenKey is a token scoped to the default cluster, which
+	// authenticates the requests to its Kubernetes API instead of the
+	// other credentials when set. It is minted after TokenKey, with
+	// the same lifetime.
+	ClusterTokenKey string
+
+	// ExpiresAt is when TokenKey expires. It is zero when the token
+	// does not expire or its expiry is unknown.
+	ExpiresAt time.Time
+}
+
+// CredentialsFromEnvVars returns the credentials in RANCHER_TOKEN,
+// RANCHER_ACCESS_KEY and RANCHER_SECRET_KEY, or RANCHER_USERNAME and
+// RANCHER_PASSWORD.
+func CredentialsFromEnvVars() (Credentials, error) {
+	c := Credentials{
+		AccessKey: os.Getenv("RANCHER_ACCESS_KEY"),
+		SecretKey: os.Getenv("RANCHER_SECRET_KEY"),
+		TokenKey:  os.Getenv("RANCHER_TOKEN"),
+		Username:  os.Getenv("RANCHER_USERNAME"),
+		Password:  os.Getenv("RANCHER_PASSWORD"),
+	}
+	if !c.HasToken() && (c.Username == "" || c.Password == "") {
+		return c, fmt.Errorf("either access/secret key, token or username/password needs to be specified")
+	}
+	return c, nil
+}
+
+// HasToken returns whether the credentials hold an API key pair or a
+// token, as opposed to a username and password only.
+func (c *Credentials) HasToken() bool {
+	return c.TokenKey != "" || (c.AccessKey != "" && c.SecretKey != "")
+}
+
+// Expired returns whether the token is expired or about to.
+func (c *Credentials) Expired() bool {
+	return !c.ExpiresAt.IsZero() && time.Now().Add(tokenExpiryMargin).After(c.ExpiresAt)
+}
+
+// AuthHeader returns the value of the Authorization header, the same
+// way norman clients build it: the token as a bearer token, or else
+// the key pair as basic credentials.
+func (c *Credentials) AuthHeader() string {
+	if c.TokenKey != "" {
+		return "Bearer " + c.TokenKey
+	}
+	if c.AccessKey != "" && c.SecretKey != "" {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.AccessKey+":"+c.SecretKey))
+	}
+	return ""
+}
+
+// Header returns the headers authenticating a request to the
+// Kubernetes API of the default cluster, such as the exec websocket
+// handshake.
+func (c *Credentials) Header() (http.Header, error) {
+	if c.Expired() {
+		return nil, fmt.Errorf("token expired at %v", c.ExpiresAt)
+	}
+	auth := c.AuthHeader()
+	if c.ClusterTokenKey != "" {
+		auth = "Bearer " + c.ClusterTokenKey
+	}
+	if auth == "" {
+		return nil, fmt.Errorf("login credentials not provided")
+	}
+	return http.Header{"Authorization": {auth}}, nil
+}
+
+// ClientOpts returns the options of a norman client for the URL.
+func (c *Credentials) ClientOpts(url string) *normanclientbase.ClientOpts {
+	return &normanclientbase.ClientOpts{
+		URL:        url,
+		AccessKey:  c.AccessKey,
+		SecretKey:  c.SecretKey,
+		TokenKey:   c.TokenKey,
+		HTTPClient: insecureClient,
+	}
+}
+
+// configure sets the credentials on the client-go config of the
+// Kubernetes API of the default cluster.
+func (c *Credentials) configure(config *rest.Config) {
+	if c.ClusterTokenKey != "" {
+		config.BearerToken = c.ClusterTokenKey
+	} else if c.TokenKey != "" {
+		config.BearerToken = c.TokenKey
+	} else {
+		config.Username = c.AccessKey
+		config.Password = c.SecretKey
+	}
+}
+
+// lookupExpiry sets ExpiresAt from the token resource of TokenKey or
+// AccessKey, and returns an error if the token is expired. Tokens which
+// cannot be looked up are assumed not to expire.
+func (c *Credentials) lookupExpiry(client *rmgmtv3.Client) error {
+	name := c.AccessKey
+	if c.TokenKey != "" {
+		name = strings.SplitN(c.TokenKey, ":", 2)[0]
+	}
+	if name == "" || !c.ExpiresAt.IsZero() {
+		return nil
+	}
+
+	token, err := client.Token.ByID(name)
+	if err != nil {
+		logrus.Debugf("could not look up token %v: %v", name, err)
+		return nil
+	}
+	if token.Expired {
+		return fmt.Errorf("token %v expired at %v", name, token.ExpiresAt)
+	}
+	c.ExpiresAt, err = parseExpiresAt(token.ExpiresAt)
+	return err
+}
+
+// TokenTTLFromEnvVars returns the lifetime in RANCHER_TOKEN_TTL of the
+// tokens minted for a run, or DefaultTokenTTL.
+func TokenTTLFromEnvVars() (time.Duration, error) {
+	v := os.Getenv("RANCHER_TOKEN_TTL")
+	if v == "" {
+		return DefaultTokenTTL, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing RANCHER_TOKEN_TTL: %v", err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("RANCHER_TOKEN_TTL must be positive, got %v", ttl)
+	}
+	return ttl, nil
+}
+
+// tokenRequest is a token to create, along with the cluster it is
+// scoped to, which the Token type of the client does not have.
+type tokenRequest struct {
+	rmgmtv3.Token
+	ClusterID string `json:"clusterId,omitempty"`
+}
+
+// MintToken creates a token which expires after ttl, and replaces the
+// token of the credentials with it. The replaced token, such as the
+// session token of Login, is deleted. The token is not scoped, since
+// it is used on /v3; see MintClusterToken.
+func (c *Credentials) MintToken(client *rmgmtv3.Client, ttl time.Duration) (*rmgmtv3.Token, error) {
+	token, expiresAt, err := mintToken(client, "", ttl)
+	if err != nil {
+		return nil, err
+	}
+	if c.TokenKey != "" {
+		name := strings.SplitN(c.TokenKey, ":", 2)[0]
+		if err := deleteToken(client, name); err != nil {
+			logrus.Warnf("error deleting token %v: %v", name, err)
+		}
+	}
+	c.TokenKey = token.Token
+	c.ExpiresAt = expiresAt
+	return token, nil
+}
+
+// MintClusterToken creates a token scoped to the cluster with the ID
+// which expires after ttl, and sets ClusterTokenKey to it. The
+// replaced cluster token, if any, is deleted.
+func (c *Credentials) MintClusterToken(client *rmgmtv3.Client, clusterID string, ttl time.Duration) (*rmgmtv3.Token, error) {
+	token, _, err := mintToken(client, clusterID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if c.ClusterTokenKey != "" {
+		name := strings.SplitN(c.ClusterTokenKey, ":", 2)[0]
+		if err := deleteToken(client, name); err != nil {
+			logrus.Warnf("error deleting token %v: %v", name, err)
+		}
+	}
+	c.ClusterTokenKey = token.Token
+	return token, nil
+}
+
+// mintToken creates a token which expires after ttl, scoped to the
+// cluster with the ID unless it is empty, and returns it with its
+// expiry.
+func mintToken(client *rmgmtv3.Client, clusterID string, ttl time.Duration) (*rmgmtv3.Token, time.Time, error) {
+	ttlMillis := int64(ttl / time.Millisecond)
+	request := &tokenRequest{
+		Token: rmgmtv3.Token{
+			Description: tokenDescription,
+			TTLMillis:   &ttlMillis,
+		},
+		ClusterID: clusterID,
+	}
+	token := &rmgmtv3.Token{}
+	if err := client.APIBaseClient.Create(rmgmtv3.TokenType, request, token); err != nil {
+		return nil, time.Time{}, fmt.Errorf("error creating token: %v", err)
+	}
+	if token.Token == "" {
+		return nil, time.Time{}, fmt.Errorf("error creating token: no token in response")
+	}
+	expiresAt, err := parseExpiresAt(token.ExpiresAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// DeleteToken deletes the tokens of the credentials from the Rancher
+// server at url, such as the session token of Login when connecting
+// to the server fails after it.
+func (c *Credentials) DeleteToken(url string) error {
+	for _, key := range []string{c.ClusterTokenKey, c.TokenKey} {
+		if key == "" {
+			continue
+		}
+		name := strings.SplitN(key, ":", 2)[0]
+		req, err := http.NewRequest(http.MethodDelete, url+"/v3/tokens/"+name, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", c.AuthHeader())
+		resp, err := insecureClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("error deleting token %v: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("error deleting token %v: %v", name, resp.Status)
+		}
+	}
+	return nil
+}
+
+// deleteToken deletes the token with the name.
+func deleteToken(client *rmgmtv3.Client, name string) error {
+	token, err := client.Token.ByID(name)
+	if err != nil {
+		return err
+	}
+	return client.Token.Delete(token)
+}
+
+// loginResponse is the token returned by a login.
+type loginResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// Login exchanges the username and password for a token with the
+// local authentication provider of the Rancher server at url.
+func (c *Credentials) Login(url string) error {
+	body, err := json.Marshal(map[string]string{
+		"username":     c.Username,
+		"password":     c.Password,
+		"responseType": "json",
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := insecureClient.Post(url+"/v3-public/localProviders/local?action=login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error logging in as %v: %v", c.Username, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error logging in as %v: %v", c.Username, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error logging in as %v: %v: %s", c.Username, resp.Status, data)
+	}
+
+	token := loginResponse{}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return fmt.Errorf("error parsing login response: %v", err)
+	}
+	if token.Token == "" {
+		return fmt.Errorf("error logging in as %v: no token in response", c.Username)
+	}
+	c.TokenKey = token.Token
+	c.ExpiresAt, err = parseExpiresAt(token.ExpiresAt)
+	return err
+}
+
+// parseExpiresAt parses the expiresAt field of a Rancher token, which
+// is empty for tokens which do not expire.
+func parseExpiresAt(expiresAt string) (time.Time, error) {
+	if expiresAt == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing token expiry %q: %v", expiresAt, err)
+	}
+	return t, nil
+}
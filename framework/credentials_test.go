@@ -0,0 +1,314 @@
+package framework
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	"k8s.io/client-go/rest"
+)
+
+func TestCredentialsAuthHeader(t *testing.T) {
+	tests := []struct {
+		c    Credentials
+		want string
+	}{
+		{Credentials{TokenKey: "token-abcde:secret"}, "Bearer token-abcde:secret"},
+		{Credentials{AccessKey: "token-abcde", SecretKey: "secret"}, "Basic dG9rZW4tYWJjZGU6c2VjcmV0"},
+		{Credentials{AccessKey: "token-abcde", SecretKey: "secret", TokenKey: "token-fghij:secret"}, "Bearer token-fghij:secret"},
+		{Credentials{Username: "admin", Password: "password"}, ""},
+	}
+	for _, test := range tests {
+		if got := test.c.AuthHeader(); got != test.want {
+			t.Errorf("AuthHeader(%+v) = %q, want %q", test.c, got, test.want)
+		}
+	}
+}
+
+func TestCredentialsExpired(t *testing.T) {
+	c := Credentials{TokenKey: "token-abcde:secret"}
+	if c.Expired() {
+		t.Errorf("token without expiry is expired")
+	}
+	c.ExpiresAt = time.Now().Add(time.Hour)
+	if c.Expired() {
+		t.Errorf("token expiring in an hour is expired")
+	}
+	c.ExpiresAt = time.Now().Add(tokenExpiryMargin / 2)
+	if !c.Expired() {
+		t.Errorf("token expiring within the margin is not expired")
+	}
+	if _, err := c.Header(); err == nil {
+		t.Errorf("Header() of an expired token succeeded")
+	}
+}
+
+func TestCredentialsLogin(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	var path string
+	var body map[string]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path + "?" + r.URL.RawQuery
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["password"] != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"token":     "token-abcde:secret",
+			"expiresAt": expiresAt.Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+
+	c := Credentials{Username: "admin", Password: "password"}
+	if err := c.Login(server.URL); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if path != "/v3-public/localProviders/local?action=login" {
+		t.Errorf("path = %v", path)
+	}
+	if body["username"] != "admin" || body["responseType"] != "json" {
+		t.Errorf("body = %v", body)
+	}
+	if c.TokenKey != "token-abcde:secret" || !c.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("credentials = %+v", c)
+	}
+
+	c = Credentials{Username: "admin", Password: "wrong"}
+	if err := c.Login(server.URL); err == nil {
+		t.Errorf("login with a wrong password succeeded")
+	}
+}
+
+func TestTokenTTLFromEnvVars(t *testing.T) {
+	defer os.Unsetenv("RANCHER_TOKEN_TTL")
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"", DefaultTokenTTL, false},
+		{"30m", 30 * time.Minute, false},
+		{"0s", 0, true},
+		{"forever", 0, true},
+	}
+	for _, test := range tests {
+		os.Setenv("RANCHER_TOKEN_TTL", test.value)
+		got, err := TokenTTLFromEnvVars()
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("TokenTTLFromEnvVars() with %q = %v, %v", test.value, got, err)
+		}
+	}
+}
+
+// tokenAPI is a fake of the token API of a Rancher server.
+type tokenAPI struct {
+	*httptest.Server
+
+	created []map[string]interface{}
+	auth    map[string]string
+	deleted []string
+}
+
+func newTokenAPI() *tokenAPI {
+	api := &tokenAPI{auth: map[string]string{}}
+	api.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		token := func(id string) map[string]interface{} {
+			return map[string]interface{}{
+				"id":    id,
+				"type":  "token",
+				"links": map[string]string{"self": api.URL + "/v3/tokens/" + id},
+			}
+		}
+		api.auth[r.Method+" "+r.URL.Path] = r.Header.Get("Authorization")
+		switch {
+		case r.URL.Path == "/v3-public/localProviders/local":
+			json.NewEncoder(w).Encode(map[string]string{"token": "token-session:secret"})
+		case r.Method == http.MethodGet && r.URL.Path == "/v3":
+			w.Header().Set("X-API-Schemas", api.URL+"/v3")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": []map[string]interface{}{{
+					"id":                "token",
+					"collectionMethods": []string{"GET", "POST"},
+					"resourceMethods":   []string{"GET", "DELETE"},
+					"links":             map[string]string{"collection": api.URL + "/v3/tokens"},
+				}},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v3/token":
+			request := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&request)
+			api.created = append(api.created, request)
+			id := "token-minted"
+			if request["clusterId"] != nil {
+				id = "token-cluster"
+			}
+			created := token(id)
+			created["token"] = id + ":secret"
+			created["expiresAt"] = "2030-01-02T03:04:05Z"
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v3/tokens/"):
+			json.NewEncoder(w).Encode(token(strings.TrimPrefix(r.URL.Path, "/v3/tokens/")))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v3/tokens/"):
+			api.deleted = append(api.deleted, strings.TrimPrefix(r.URL.Path, "/v3/tokens/"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return api
+}
+
+func TestMintTokenAndClose(t *testing.T) {
+	api := newTokenAPI()
+	defer api.Close()
+
+	c := Credentials{TokenKey: "token-session:secret"}
+	client, err := rmgmtv3.NewClient(c.ClientOpts(api.URL + "/v3"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	token, err := c.MintToken(client, time.Hour)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(api.created) != 1 {
+		t.Fatalf("expected a token request, got %v", api.created)
+	}
+	if _, scoped := api.created[0]["clusterId"]; scoped {
+		t.Errorf("expected an unscoped token, got %v", api.created[0])
+	}
+	if api.created[0]["ttl"] != float64(3600000) || api.created[0]["description"] != tokenDescription {
+		t.Errorf("unexpected token request %v", api.created[0])
+	}
+	if api.auth["POST /v3/token"] != "Bearer token-session:secret" {
+		t.Errorf("expected the token to be minted with the session token, got %v", api.auth["POST /v3/token"])
+	}
+	if c.TokenKey != "token-minted:secret" || !c.ExpiresAt.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("credentials = %+v", c)
+	}
+	if !reflect.DeepEqual(api.deleted, []string{"token-session"}) {
+		t.Errorf("expected the session token to be deleted, deleted %v", api.deleted)
+	}
+
+	client, err = rmgmtv3.NewClient(c.ClientOpts(api.URL + "/v3"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rs := &RancherServer{Credentials: c, ManagementClient: client, MintedToken: token}
+	if err := rs.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(api.deleted, []string{"token-session", "token-minted"}) {
+		t.Errorf("expected the minted token to be deleted, deleted %v", api.deleted)
+	}
+	if api.auth["DELETE /v3/tokens/token-minted"] != "Bearer token-minted:secret" {
+		t.Errorf("expected the minted token to delete itself, got %v", api.auth["DELETE /v3/tokens/token-minted"])
+	}
+	if rs.MintedToken != nil {
+		t.Errorf("expected the minted token to be forgotten")
+	}
+	if err := rs.Close(); err != nil || len(api.deleted) != 2 {
+		t.Errorf("expected closing again to do nothing, got %v and deleted %v", err, api.deleted)
+	}
+}
+
+func TestMintClusterToken(t *testing.T) {
+	api := newTokenAPI()
+	defer api.Close()
+
+	c := Credentials{TokenKey: "token-minted:secret"}
+	client, err := rmgmtv3.NewClient(c.ClientOpts(api.URL + "/v3"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	token, err := c.MintClusterToken(client, "c-abcde", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(api.created) != 1 {
+		t.Fatalf("expected a token request, got %v", api.created)
+	}
+	if api.created[0]["clusterId"] != "c-abcde" || api.created[0]["ttl"] != float64(1800000) {
+		t.Errorf("expected a token scoped to c-abcde for 30m, got %v", api.created[0])
+	}
+	if c.TokenKey != "token-minted:secret" || c.ClusterTokenKey != "token-cluster:secret" {
+		t.Errorf("credentials = %+v", c)
+	}
+	if len(api.deleted) != 0 {
+		t.Errorf("expected no token to be deleted, deleted %v", api.deleted)
+	}
+
+	// The cluster token authenticates the Kubernetes API only.
+	if c.AuthHeader() != "Bearer token-minted:secret" {
+		t.Errorf("unexpected authorization %v", c.AuthHeader())
+	}
+	if h, err := c.Header(); err != nil || h.Get("Authorization") != "Bearer token-cluster:secret" {
+		t.Errorf("unexpected exec headers %v, %v", h, err)
+	}
+	config := &rest.Config{}
+	c.configure(config)
+	if config.BearerToken != "token-cluster:secret" {
+		t.Errorf("unexpected Kubernetes credentials %+v", config)
+	}
+
+	rs := &RancherServer{Credentials: c, ManagementClient: client, MintedClusterToken: token}
+	if err := rs.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(api.deleted, []string{"token-cluster"}) {
+		t.Errorf("expected the cluster token to be deleted, deleted %v", api.deleted)
+	}
+}
+
+func TestCredentialsDeleteToken(t *testing.T) {
+	api := newTokenAPI()
+	defer api.Close()
+
+	c := Credentials{TokenKey: "token-session:secret"}
+	if err := c.DeleteToken(api.URL); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(api.deleted, []string{"token-session"}) {
+		t.Errorf("deleted %v", api.deleted)
+	}
+	if api.auth["DELETE /v3/tokens/token-session"] != "Bearer token-session:secret" {
+		t.Errorf("unexpected authorization %v", api.auth["DELETE /v3/tokens/token-session"])
+	}
+
+	api.deleted = nil
+	c = Credentials{TokenKey: "token-minted:secret", ClusterTokenKey: "token-cluster:secret"}
+	if err := c.DeleteToken(api.URL); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(api.deleted, []string{"token-cluster", "token-minted"}) {
+		t.Errorf("expected both tokens to be deleted, deleted %v", api.deleted)
+	}
+}
+
+func TestNewRancherServerDeletesSessionTokenOnFailure(t *testing.T) {
+	api := newTokenAPI()
+	defer api.Close()
+
+	// The fake has no cluster schema, so listing the clusters fails
+	// after the login.
+	_, err := newRancherServer(api.URL, "", Credentials{Username: "admin", Password: "password"}, testBackoff)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !reflect.DeepEqual(api.deleted, []string{"token-session"}) {
+		t.Errorf("expected the session token to be deleted, deleted %v", api.deleted)
+	}
+}
@@ -0,0 +1,183 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	normantypes "github.com/rancher/norman/types"
+	"github.com/rancher/test-network-policy/policy"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// NewProbeDaemonSet returns a DaemonSet running the ProbeImage on every
+// node, whose pods are labelled with WorkloadLabel.
+func NewProbeDaemonSet(name, namespace string) *rprojectv3.DaemonSet {
+	return &rprojectv3.DaemonSet{
+		Name:        name,
+		NamespaceId: namespace,
+		DNSPolicy:   "ClusterFirst",
+		Labels:      map[string]string{WorkloadLabel: name},
+		Containers: []rprojectv3.Container{
+			{
+				Name:  name,
+				Image: ProbeImage(),
+				Stdin: true,
+				TTY:   true,
+
+				ReadinessProbe: NewReadinessProbe(),
+			},
+		},
+	}
+}
+
+// CreateDaemonSet creates the DaemonSet with the project client.
+func CreateDaemonSet(client *rprojectv3.Client, ds *rprojectv3.DaemonSet) (*rprojectv3.DaemonSet, error) {
+	var created *rprojectv3.DaemonSet
+	err := RetryCreate(clientBackoff(client.Opts), func() error {
+		var err error
+		created, err = client.DaemonSet.Create(ds)
+		return err
+	}, func() (bool, error) {
+		collection, err := client.DaemonSet.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"namespaceId": ds.NamespaceId, "name": ds.Name},
+		})
+		if err != nil || len(collection.Data) == 0 {
+			return false, err
+		}
+		created = &collection.Data[0]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating daemon set %v: %v", ds.Name, err)
+	}
+	return created, nil
+}
+
+// WaitForDaemonSetEndpoints waits for the probe DaemonSet to have a
+// ready pod on each of the nodes, and returns their endpoints in the
+// order of the nodes.
+func (c *KubeClient) WaitForDaemonSetEndpoints(namespace, name string, nodes []string, timeout time.Duration) ([]Endpoint, error) {
+	var byNode map[string]Endpoint
+	err := wait.PollImmediate(waitInterval, timeout, func() (bool, error) {
+		pods := &corev1.PodList{}
+		opts := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(map[string]string{WorkloadLabel: name}).String()}
+		if err := c.ListObjects(PodResource, namespace, opts, pods); err != nil {
+			return false, nil
+		}
+		byNode = map[string]Endpoint{}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.DeletionTimestamp != nil || !podReady(pod) || pod.Status.PodIP == "" {
+				continue
+			}
+			byNode[pod.Spec.NodeName] = Endpoint{
+				Workload:  name,
+				Namespace: namespace,
+				Pod:       pod.Name,
+				Container: name,
+				Labels:    pod.Labels,
+				PodIP:     pod.Status.PodIP,
+				Node:      pod.Spec.NodeName,
+				PerNode:   true,
+			}
+		}
+		return len(missingNodes(nodes, byNode)) == 0, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for daemon set %v/%v to be ready on nodes %v", namespace, name, missingNodes(nodes, byNode))
+	}
+
+	var endpoints []Endpoint
+	for _, node := range nodes {
+		endpoints = append(endpoints, byNode[node])
+	}
+	return endpoints, nil
+}
+
+func missingNodes(nodes []string, byNode map[string]Endpoint) []string {
+	var missing []string
+	for _, node := range nodes {
+		if _, ok := byNode[node]; !ok {
+			missing = append(missing, node)
+		}
+	}
+	return missing
+}
+
+// NodeAnomaly counts the probes from and to the pods on a node which
+// did not observe the expected reachability, or were inconclusive.
+type NodeAnomaly struct {
+	Node       string
+	FailedFrom int
+	ProbesFrom int
+	FailedTo   int
+	ProbesTo   int
+}
+
+// Failed returns the number of failed probes from and to the node.
+func (a NodeAnomaly) Failed() int {
+	return a.FailedFrom + a.FailedTo
+}
+
+func (a NodeAnomaly) String() string {
+	return fmt.Sprintf("%v: %d of %d probes from it and %d of %d probes to it failed",
+		a.Node, a.FailedFrom, a.ProbesFrom, a.FailedTo, a.ProbesTo)
+}
+
+// NodeAnomalies returns the nodes with probes from or to their pods
+// which failed, the nodes with most failures first. A node whose CNI
+// agent is broken stands out with all of its probes failed, while the
+// other nodes only fail the probes to or from it.
+func NodeAnomalies(expected policy.Matrix, results []ProbeResult) []NodeAnomaly {
+	byNode := map[string]*NodeAnomaly{}
+	anomaly := func(node string) *NodeAnomaly {
+		if byNode[node] == nil {
+			byNode[node] = &NodeAnomaly{Node: node}
+		}
+		return byNode[node]
+	}
+
+	for _, r := range results {
+		from, to := anomaly(r.Pair.From.Node), anomaly(r.Pair.To.Node)
+		from.ProbesFrom++
+		to.ProbesTo++
+
+		failed := !r.Outcome.Conclusive()
+		if reachable, ok := expected.Get(r.Pair.From.String(), r.Pair.To.String()); ok && !failed {
+			failed = reachable != r.Reachable
+		}
+		if failed {
+			from.FailedFrom++
+			to.FailedTo++
+		}
+	}
+
+	var anomalies []NodeAnomaly
+	for _, a := range byNode {
+		if a.Failed() > 0 {
+			anomalies = append(anomalies, *a)
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Failed() != anomalies[j].Failed() {
+			return anomalies[i].Failed() > anomalies[j].Failed()
+		}
+		return anomalies[i].Node < anomalies[j].Node
+	})
+	return anomalies
+}
+
+// FormatNodeAnomalies returns the anomalies one per line.
+func FormatNodeAnomalies(anomalies []NodeAnomaly) string {
+	var lines []string
+	for _, a := range anomalies {
+		lines = append(lines, a.String())
+	}
+	return strings.Join(lines, "\n")
+}
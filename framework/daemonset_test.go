@@ -0,0 +1,57 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rancher/test-network-policy/policy"
+)
+
+func TestNodeAnomalies(t *testing.T) {
+	var endpoints []Endpoint
+	for _, ns := range []string{"ns1", "ns2"} {
+		for _, node := range []string{"node1", "node2", "node3"} {
+			endpoints = append(endpoints, Endpoint{Workload: "ds", Namespace: ns, Node: node, PerNode: true})
+		}
+	}
+
+	// Pods reach the pods of their namespace only, except on node3,
+	// whose probes all time out.
+	expected := policy.Matrix{}
+	var results []ProbeResult
+	for _, from := range endpoints {
+		for _, to := range endpoints {
+			if from.String() == to.String() {
+				continue
+			}
+			reachable := from.Namespace == to.Namespace
+			expected.Set(from.String(), to.String(), reachable)
+
+			r := ProbeResult{Pair: Pair{From: from, To: to, Via: ViaPodIP}, Reachable: reachable, Outcome: Success}
+			if !reachable {
+				r.Outcome = Refused
+			}
+			if from.Node == "node3" || to.Node == "node3" {
+				r.Reachable, r.Outcome = false, Timeout
+			}
+			results = append(results, r)
+		}
+	}
+
+	got := NodeAnomalies(expected, results)
+	want := []NodeAnomaly{
+		{Node: "node3", FailedFrom: 4, ProbesFrom: 10, FailedTo: 4, ProbesTo: 10},
+		{Node: "node1", FailedFrom: 2, ProbesFrom: 10, FailedTo: 2, ProbesTo: 10},
+		{Node: "node2", FailedFrom: 2, ProbesFrom: 10, FailedTo: 2, ProbesTo: 10},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("anomalies = %v, want %v", got, want)
+	}
+
+	if got := NodeAnomalies(expected, nil); len(got) != 0 {
+		t.Errorf("anomalies without probes = %v", got)
+	}
+	if got := endpoints[0].String(); got != "ns1/ds@node1" {
+		t.Errorf("endpoint = %v", got)
+	}
+}
@@ -0,0 +1,120 @@
+package framework
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/test-network-policy/utils"
+)
+
+// Executor runs commands in the containers of a cluster. RancherServer
+// runs them through the Rancher proxy, and KubeCluster directly
+// through the Kubernetes API.
+type Executor interface {
+	Exec(namespace, pod, container, command string) (string, error)
+}
+
+// ExecTranscript is a command run in a container and its output.
+type ExecTranscript struct {
+	Start     time.Time
+	Duration  time.Duration
+	Namespace string
+	Pod       string
+	Container string
+	Command   string
+	Output    string
+	Err       error
+}
+
+// TranscriptRecorder collects the transcripts of the commands run
+// in containers. It is safe for concurrent use.
+type TranscriptRecorder struct {
+	mu          sync.Mutex
+	transcripts []ExecTranscript
+}
+
+// Record adds the transcript.
+func (r *TranscriptRecorder) Record(t ExecTranscript) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transcripts = append(r.transcripts, t)
+}
+
+// Transcripts returns the recorded transcripts.
+func (r *TranscriptRecorder) Transcripts() []ExecTranscript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ExecTranscript(nil), r.transcripts...)
+}
+
+// Reset drops the recorded transcripts.
+func (r *TranscriptRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transcripts = nil
+}
+
+// WriteTranscripts writes the transcripts in a human readable form.
+func WriteTranscripts(w io.Writer, transcripts []ExecTranscript) error {
+	for _, t := range transcripts {
+		fmt.Fprintf(w, "=== %v %v/%v/%v (%v)\n$ %v\n%v\n",
+			t.Start.Format(time.RFC3339), t.Namespace, t.Pod, t.Container, t.Duration, t.Command, t.Output)
+		if t.Err != nil {
+			fmt.Fprintf(w, "error: %v\n", t.Err)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exec runs the command in the container of the pod through the
+// Rancher proxy and returns its output. The command is recorded in
+// Transcripts if set.
+func (rs *RancherServer) Exec(namespace, pod, container, command string) (string, error) {
+	wsURL := utils.GetWSURL(rs.URL, rs.DefaultCluster.ID, namespace, pod, container, command)
+	return recordExec(rs.Transcripts, namespace, pod, container, command, func() (string, error) {
+		h, err := rs.Header()
+		if err != nil {
+			return "", err
+		}
+		return utils.RunExecCommandWithHeader(wsURL, h, &tls.Config{InsecureSkipVerify: true})
+	})
+}
+
+// IsExecForbidden returns whether the exec failed because the
+// credentials are not allowed to exec into the pod.
+func IsExecForbidden(err error) bool {
+	handshakeErr, ok := err.(*utils.HandshakeError)
+	return ok && handshakeErr.StatusCode == http.StatusForbidden
+}
+
+// recordExec runs exec and records its transcript in the recorder,
+// if not nil.
+func recordExec(recorder *TranscriptRecorder, namespace, pod, container, command string, exec func() (string, error)) (string, error) {
+	start := time.Now()
+	output, err := exec()
+	if recorder != nil {
+		recorder.Record(ExecTranscript{
+			Start:     start,
+			Duration:  time.Since(start),
+			Namespace: namespace,
+			Pod:       pod,
+			Container: container,
+			Command:   command,
+			Output:    output,
+			Err:       err,
+		})
+	}
+	return output, err
+}
+
+var (
+	_ Executor = &RancherServer{}
+	_ Executor = &KubeCluster{}
+)
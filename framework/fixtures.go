@@ -0,0 +1,169 @@
+package framework
+
+import (
+	"fmt"
+	"time"
+
+	normanclientbase "github.com/rancher/norman/clientbase"
+	normantypes "github.com/rancher/norman/types"
+	rclusterv3 "github.com/rancher/types/client/cluster/v3"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultImage is the image used by the probe workloads unless
+	// AgentImage is set. It serves its pod name over HTTP and ships
+	// curl.
+	DefaultImage = "leodotcloud/swiss-army-knife"
+
+	// DefaultWaitTimeout is the time fixtures are given to become active.
+	DefaultWaitTimeout = 60 * time.Second
+
+	waitInterval = 1 * time.Second
+)
+
+// CreateProject creates a project labelled with TestResourceLabel
+// in the default cluster and waits for it to become active.
+func (rs *RancherServer) CreateProject(name string) (*rmgmtv3.Project, error) {
+	var project *rmgmtv3.Project
+	err := RetryCreate(rs.Backoff, func() error {
+		var err error
+		project, err = rs.ManagementClient.Project.Create(&rmgmtv3.Project{
+			Name:      name,
+			ClusterId: rs.DefaultCluster.ID,
+			Labels:    TestResourceLabels(),
+		})
+		return err
+	}, func() (bool, error) {
+		collection, err := rs.ManagementClient.Project.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"clusterId": rs.DefaultCluster.ID, "name": name},
+		})
+		if err != nil || len(collection.Data) == 0 {
+			return false, err
+		}
+		project = &collection.Data[0]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating project %v: %v", name, err)
+	}
+
+	err = wait.PollImmediate(waitInterval, DefaultWaitTimeout, func() (bool, error) {
+		p, err := rs.ManagementClient.Project.ByID(project.ID)
+		return err == nil && p.State == "active", nil
+	})
+	if err != nil {
+		return project, fmt.Errorf("error waiting for project %v to become active: %v", name, err)
+	}
+	return project, nil
+}
+
+// CreateNamespace creates a namespace in the project and waits
+// for it to become active.
+func (rs *RancherServer) CreateNamespace(name, projectID string, labels map[string]string) (*rclusterv3.Namespace, error) {
+	var ns *rclusterv3.Namespace
+	err := RetryCreate(rs.Backoff, func() error {
+		var err error
+		ns, err = rs.DefaultClusterClient.Namespace.Create(&rclusterv3.Namespace{
+			Name:      name,
+			ProjectID: projectID,
+			Labels:    labels,
+		})
+		return err
+	}, func() (bool, error) {
+		existing, err := rs.DefaultClusterClient.Namespace.ByID(name)
+		if normanclientbase.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		ns = existing
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating namespace %v: %v", name, err)
+	}
+
+	err = wait.PollImmediate(waitInterval, DefaultWaitTimeout, func() (bool, error) {
+		n, err := rs.DefaultClusterClient.Namespace.ByID(ns.ID)
+		return err == nil && n.State == "active", nil
+	})
+	if err != nil {
+		return ns, fmt.Errorf("error waiting for namespace %v to become active: %v", name, err)
+	}
+	return ns, nil
+}
+
+// NewProbeWorkload returns a single pod deployment running the
+// ProbeImage, which can be probed and probe others.
+func NewProbeWorkload(name, namespace string, labels map[string]string) *rprojectv3.Workload {
+	return &rprojectv3.Workload{
+		Name:        name,
+		NamespaceId: namespace,
+		DNSPolicy:   "ClusterFirst",
+		Labels:      labels,
+		Containers: []rprojectv3.Container{
+			{
+				Name:  name,
+				Image: ProbeImage(),
+				Stdin: true,
+				TTY:   true,
+
+				ReadinessProbe: NewReadinessProbe(),
+
+				// Expose the workload through a ClusterIP service of
+				// its name, rather than a headless one.
+				Ports: []rprojectv3.ContainerPort{
+					{
+						Name:          "http",
+						ContainerPort: func(i int64) *int64 { return &i }(80),
+						Protocol:      "TCP",
+						Kind:          "ClusterIP",
+						DNSName:       name,
+					},
+				},
+			},
+		},
+		DeploymentConfig: &rprojectv3.DeploymentConfig{
+			MaxSurge:             intstr.FromInt(1),
+			Strategy:             "RollingUpdate",
+			RevisionHistoryLimit: func(i int64) *int64 { return &i }(10),
+		},
+	}
+}
+
+// CreateWorkload creates the workload with the project client and
+// waits for it to become active.
+func CreateWorkload(client *rprojectv3.Client, workload *rprojectv3.Workload) (*rprojectv3.Workload, error) {
+	var w *rprojectv3.Workload
+	err := RetryCreate(clientBackoff(client.Opts), func() error {
+		var err error
+		w, err = client.Workload.Create(workload)
+		return err
+	}, func() (bool, error) {
+		collection, err := client.Workload.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"namespaceId": workload.NamespaceId, "name": workload.Name},
+		})
+		if err != nil || len(collection.Data) == 0 {
+			return false, err
+		}
+		w = &collection.Data[0]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating workload %v: %v", workload.Name, err)
+	}
+
+	err = wait.PollImmediate(waitInterval, DefaultWaitTimeout, func() (bool, error) {
+		current, err := client.Workload.ByID(w.ID)
+		return err == nil && current.State == "active", nil
+	})
+	if err != nil {
+		return w, fmt.Errorf("error waiting for workload %v to become active: %v", workload.Name, err)
+	}
+	return w, nil
+}
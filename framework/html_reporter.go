@@ -0,0 +1,255 @@
+package framework
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/onsi/ginkgo/types"
+)
+
+// HTMLReporter is a ginkgo reporter writing a single static HTML
+// page showing, for every spec, the topology it probed, the expected
+// and observed reachability grid, and the probes behind every cell.
+type HTMLReporter struct {
+	reportBuilder
+	filename string
+}
+
+// NewHTMLReporter returns an HTMLReporter writing to filename. The
+// recorders may be nil.
+func NewHTMLReporter(filename string, connectivity *ConnectivityRecorder, convergence *ConvergenceRecorder, scale *ScaleRecorder) *HTMLReporter {
+	return &HTMLReporter{
+		reportBuilder: reportBuilder{
+			connectivity: connectivity,
+			convergence:  convergence,
+			scale:        scale,
+		},
+		filename: filename,
+	}
+}
+
+func (r *HTMLReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.finish(summary)
+
+	f, err := os.Create(r.filename)
+	if err != nil {
+		fmt.Printf("Failed to write HTML report %v: %v\n", r.filename, err)
+		return
+	}
+	defer f.Close()
+
+	if err := htmlTemplate.Execute(f, newHTMLReport(r.report)); err != nil {
+		fmt.Printf("Failed to generate HTML report: %v\n", err)
+	}
+}
+
+type htmlReport struct {
+	JSONReport
+	Specs []htmlSpec
+}
+
+type htmlSpec struct {
+	JSONSpec
+	ID           string
+	Connectivity []htmlConnectivity
+}
+
+type htmlConnectivity struct {
+	Connectivity
+	Keys   []string
+	Rows   []htmlRow
+	Probes []htmlProbe
+}
+
+type htmlRow struct {
+	Source string
+	Cells  []htmlCell
+}
+
+type htmlCell struct {
+	Class  string
+	Text   string
+	Title  string
+	Anchor string
+}
+
+type htmlProbe struct {
+	ProbeRecord
+	Anchor   string
+	Class    string
+	Expected string
+	Observed string
+	Latency  string
+}
+
+func newHTMLReport(report JSONReport) htmlReport {
+	result := htmlReport{JSONReport: report}
+	for i, spec := range report.Specs {
+		s := htmlSpec{JSONSpec: spec, ID: fmt.Sprintf("spec-%d", i)}
+		for j, c := range spec.Connectivity {
+			s.Connectivity = append(s.Connectivity, newHTMLConnectivity(fmt.Sprintf("%v-%d", s.ID, j), c))
+		}
+		result.Specs = append(result.Specs, s)
+	}
+	return result
+}
+
+func newHTMLConnectivity(id string, c Connectivity) htmlConnectivity {
+	result := htmlConnectivity{Connectivity: c}
+
+	probes := map[string]htmlProbe{}
+	for i, p := range c.Probes {
+		probe := htmlProbe{
+			ProbeRecord: p,
+			Anchor:      fmt.Sprintf("%v-probe-%d", id, i),
+			Observed:    reachability(p.Reachable, true),
+			Latency:     (time.Duration(p.Latency * float64(time.Second))).Round(time.Millisecond).String(),
+		}
+		expected, ok := c.Expected.Get(p.From, p.To)
+		probe.Expected = reachability(expected, ok)
+		probe.Class = cellClass(expected, ok, p.Reachable)
+		if outcome := ProbeOutcome(p.Outcome); outcome != "" && !outcome.Conclusive() {
+			probe.Observed = p.Outcome
+			probe.Class = "inconclusive"
+		}
+		probes[p.From+" "+p.To] = probe
+		result.Probes = append(result.Probes, probe)
+	}
+
+	seen := map[string]bool{}
+	var keys []string
+	for _, k := range append(c.Observed.Keys(), c.Expected.Keys()...) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	result.Keys = keys
+
+	for _, src := range keys {
+		row := htmlRow{Source: src}
+		for _, dst := range keys {
+			cell := htmlCell{Class: "none", Text: "-"}
+			if probe, ok := probes[src+" "+dst]; ok {
+				cell = htmlCell{
+					Class:  probe.Class,
+					Text:   probe.Latency,
+					Title:  fmt.Sprintf("%v -> %v\nexpected: %v\nobserved: %v\n%v", src, dst, probe.Expected, probe.Observed, probe.Output),
+					Anchor: probe.Anchor,
+				}
+			} else if expected, ok := c.Expected.Get(src, dst); ok {
+				cell = htmlCell{Class: "unprobed", Text: "?", Title: "expected " + reachability(expected, true) + ", not probed"}
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+func reachability(reachable, known bool) string {
+	if !known {
+		return "unknown"
+	}
+	if reachable {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func cellClass(expected, known, observed bool) string {
+	switch {
+	case known && expected != observed:
+		return "mismatch"
+	case observed:
+		return "allowed"
+	default:
+		return "denied"
+	}
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Suite}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; font-size: 0.9em; }
+th { background: #f4f4f4; }
+td.allowed { background: #c8f0c8; }
+td.denied { background: #e0e0e0; }
+td.mismatch { background: #f4a8a8; font-weight: bold; }
+td.inconclusive { background: #f4d58d; font-weight: bold; }
+td.unprobed { background: #f8e8a0; }
+td.none { background: #fff; color: #aaa; }
+td a { color: inherit; text-decoration: none; }
+.state { padding: 0.1em 0.5em; border-radius: 0.3em; color: #fff; font-size: 0.8em; }
+.state.passed { background: #3a3; }
+.state.failed, .state.panicked, .state.timedout { background: #c33; }
+.state.skipped, .state.pending { background: #999; }
+pre { background: #f8f8f8; padding: 0.5em; white-space: pre-wrap; max-height: 20em; overflow: auto; }
+.spec { border-top: 2px solid #ddd; padding-top: 1em; margin-top: 2em; }
+</style>
+</head>
+<body>
+<h1>{{.Suite}}</h1>
+<p>
+Started {{.Started.Format "2006-01-02 15:04:05 MST"}}, took {{printf "%.0f" .Duration}}s:
+{{.Passed}} passed, {{.Failed}} failed, {{.Skipped}} skipped, {{.Pending}} pending.
+</p>
+{{if .Convergence}}
+<h2>Convergence</h2>
+<table>
+<tr><th>kind</th><th>count</th><th>failed</th><th>p50</th><th>p90</th><th>p99</th><th>max</th></tr>
+{{range .Convergence}}<tr><td>{{.Kind}}</td><td>{{.Count}}</td><td>{{.Failed}}</td><td>{{printf "%.1f" .P50}}s</td><td>{{printf "%.1f" .P90}}s</td><td>{{printf "%.1f" .P99}}s</td><td>{{printf "%.1f" .Max}}s</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Scale}}
+<h2>Scale</h2>
+<table>
+<tr><th>scale</th><th>endpoints</th><th>pairs</th><th>sampled</th><th>creation</th><th>policies</th><th>kind</th><th>p50</th><th>p99</th><th>max</th></tr>
+{{range .Scale}}{{$s := .}}{{if .Convergence}}{{range .Convergence}}<tr><td>{{$s.Spec}}</td><td>{{$s.Endpoints}}</td><td>{{$s.Pairs}}</td><td>{{$s.Sampled}}</td><td>{{printf "%.0f" $s.Creation}}s</td><td>{{$s.Policies}}</td><td>{{.Kind}}</td><td>{{printf "%.1f" .P50}}s</td><td>{{printf "%.1f" .P99}}s</td><td>{{printf "%.1f" .Max}}s</td></tr>
+{{end}}{{else}}<tr><td>{{.Spec}}</td><td>{{.Endpoints}}</td><td>{{.Pairs}}</td><td>{{.Sampled}}</td><td>{{printf "%.0f" .Creation}}s</td><td>{{.Policies}}</td><td colspan="4"></td></tr>
+{{end}}{{end}}</table>
+{{end}}
+<p>
+Cells: <span style="background:#c8f0c8">allowed</span>, <span style="background:#e0e0e0">denied</span>,
+<span style="background:#f4a8a8">observed differs from expected</span>, <span style="background:#f8e8a0">expected but not probed</span>.
+Rows are sources, columns destinations. Click a cell for the output of its probe.
+</p>
+{{range .Specs}}
+<div class="spec" id="{{.ID}}">
+<h2><span class="state {{.State}}">{{.State}}</span> {{.Name}}</h2>
+<p>Took {{printf "%.1f" .Duration}}s.</p>
+{{with .Failure}}<pre>{{.Message}}
+
+{{.Location}}</pre>{{end}}
+{{range .Connectivity}}
+<h3>{{.Name}}</h3>
+<ul>
+{{range .Topology}}<li>project {{if .Project}}{{.Project}}{{else}}<i>none</i>{{end}}<ul>
+{{range .Namespaces}}<li>namespace {{.Namespace}}: {{range $i, $w := .Workloads}}{{if $i}}, {{end}}{{$w}}{{end}}</li>
+{{end}}</ul></li>
+{{end}}</ul>
+<table>
+<tr><th></th>{{range .Keys}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.Source}}</th>{{range .Cells}}<td class="{{.Class}}" title="{{.Title}}">{{if .Anchor}}<a href="#{{.Anchor}}">{{.Text}}</a>{{else}}{{.Text}}{{end}}</td>{{end}}</tr>
+{{end}}</table>
+<h4>Probes</h4>
+<table>
+<tr><th>from</th><th>to</th><th>expected</th><th>observed</th><th>latency</th><th>output</th></tr>
+{{range .Probes}}<tr id="{{.Anchor}}"><td>{{.From}}</td><td>{{.To}}</td><td>{{.Expected}}</td><td class="{{.Class}}">{{.Observed}}</td><td>{{.Latency}}</td><td><pre>{{.Output}}{{if .Error}}
+error: {{.Error}}{{end}}</pre></td></tr>
+{{end}}</table>
+{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
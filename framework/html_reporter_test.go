@@ -0,0 +1,67 @@
+package framework
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	"github.com/rancher/test-network-policy/policy"
+)
+
+func TestHTMLReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html-reporter")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w1 := Endpoint{Project: "p-1", Workload: "w1", Namespace: "ns1"}
+	w2 := Endpoint{Project: "p-2", Workload: "w2", Namespace: "ns2"}
+	expected := policy.Matrix{}
+	expected.Set("ns1/w1", "ns2/w2", false)
+	expected.Set("ns2/w2", "ns1/w1", false)
+	expected.Set("ns1/w1", "ns3/w3", false)
+	connectivity := &ConnectivityRecorder{}
+	connectivity.Record("ProjectIsolation isolates", NewConnectivity("isolation", expected, []ProbeResult{
+		{Pair: Pair{From: w1, To: w2}, Reachable: true, Outcome: Success, Output: "<w2>", Latency: time.Second},
+		{Pair: Pair{From: w2, To: w1}, Outcome: DNSFailure, Latency: time.Second},
+	}))
+
+	scale := &ScaleRecorder{}
+	scale.Record(ScaleResult{Spec: ScaleSpec{Projects: 2, Namespaces: 1, Workloads: 1}, Creation: 90 * time.Second, Policies: 4})
+
+	filename := filepath.Join(dir, "report.html")
+	r := NewHTMLReporter(filename, connectivity, nil, scale)
+	r.SpecSuiteWillBegin(config.GinkgoConfigType{}, &types.SuiteSummary{SuiteDescription: "Suite"})
+	r.SpecDidComplete(&types.SpecSummary{
+		ComponentTexts: []string{"[Top Level]", "ProjectIsolation", "isolates"},
+		State:          types.SpecStatePassed,
+	})
+	r.SpecSuiteDidEnd(&types.SuiteSummary{NumberOfPassedSpecs: 1})
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	html := string(data)
+	for _, want := range []string{
+		"ProjectIsolation isolates",
+		"project p-1",
+		`<td class="mismatch"`,
+		`<td class="unprobed"`,
+		`<td class="inconclusive"`,
+		"dns-failure",
+		"&lt;w2&gt;",
+		"<td>2x1x1</td>",
+		"<td>90s</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected report to contain %q:\n%v", want, html)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+// JSONSpec is the result of a single spec in the JSON report.
+type JSONSpec struct {
+	Name         string         `json:"name"`
+	State        string         `json:"state"`
+	Duration     float64        `json:"durationSeconds"`
+	Failure      *JSONFailure   `json:"failure,omitempty"`
+	Connectivity []Connectivity `json:"connectivity,omitempty"`
+}
+
+// JSONFailure is the failure of a spec in the JSON report.
+type JSONFailure struct {
+	Message  string `json:"message"`
+	Location string `json:"location"`
+}
+
+// JSONConvergence holds the convergence percentiles of one kind,
+// in seconds, in the JSON report.
+type JSONConvergence struct {
+	Kind   ConvergenceKind `json:"kind"`
+	Count  int             `json:"count"`
+	Failed int             `json:"failed"`
+	P50    float64         `json:"p50Seconds"`
+	P90    float64         `json:"p90Seconds"`
+	P99    float64         `json:"p99Seconds"`
+	Max    float64         `json:"maxSeconds"`
+}
+
+// JSONScale is the result of a scale spec in the JSON report, with its
+// durations in seconds.
+type JSONScale struct {
+	Spec        string            `json:"spec"`
+	Endpoints   int               `json:"endpoints"`
+	Pairs       int               `json:"pairs"`
+	Sampled     int               `json:"sampled"`
+	Creation    float64           `json:"creationSeconds"`
+	Policies    int               `json:"policies"`
+	Convergence []JSONConvergence `json:"convergence,omitempty"`
+}
+
+// JSONReport is the document written by the JSONReporter.
+type JSONReport struct {
+	Suite       string            `json:"suite"`
+	Succeeded   bool              `json:"succeeded"`
+	Started     time.Time         `json:"started"`
+	Duration    float64           `json:"durationSeconds"`
+	Passed      int               `json:"passed"`
+	Failed      int               `json:"failed"`
+	Skipped     int               `json:"skipped"`
+	Pending     int               `json:"pending"`
+	Specs       []JSONSpec        `json:"specs"`
+	Convergence []JSONConvergence `json:"convergence,omitempty"`
+	Scale       []JSONScale       `json:"scale,omitempty"`
+}
+
+// reportBuilder implements the ginkgo reporter callbacks common to
+// the file reporters, building a JSONReport of the suite.
+type reportBuilder struct {
+	connectivity *ConnectivityRecorder
+	convergence  *ConvergenceRecorder
+	scale        *ScaleRecorder
+	report       JSONReport
+}
+
+func (r *reportBuilder) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	r.report = JSONReport{
+		Suite:   summary.SuiteDescription,
+		Started: time.Now(),
+		Specs:   []JSONSpec{},
+	}
+}
+
+func (r *reportBuilder) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+	r.addSetup("BeforeSuite", setupSummary)
+}
+
+func (r *reportBuilder) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+	r.addSetup("AfterSuite", setupSummary)
+}
+
+func (r *reportBuilder) addSetup(name string, setupSummary *types.SetupSummary) {
+	if !setupSummary.State.IsFailure() {
+		return
+	}
+	r.report.Specs = append(r.report.Specs, JSONSpec{
+		Name:     name,
+		State:    specState(setupSummary.State),
+		Duration: setupSummary.RunTime.Seconds(),
+		Failure:  newJSONFailure(setupSummary.Failure),
+	})
+}
+
+func (r *reportBuilder) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (r *reportBuilder) SpecDidComplete(specSummary *types.SpecSummary) {
+	name := SpecFullText(specSummary)
+	spec := JSONSpec{
+		Name:     name,
+		State:    specState(specSummary.State),
+		Duration: specSummary.RunTime.Seconds(),
+	}
+	if specSummary.State.IsFailure() {
+		spec.Failure = newJSONFailure(specSummary.Failure)
+	}
+	if r.connectivity != nil {
+		spec.Connectivity = r.connectivity.Get(name)
+	}
+	r.report.Specs = append(r.report.Specs, spec)
+}
+
+// finish completes the report with the summary of the suite.
+func (r *reportBuilder) finish(summary *types.SuiteSummary) {
+	r.report.Succeeded = summary.SuiteSucceeded
+	r.report.Duration = summary.RunTime.Seconds()
+	r.report.Passed = summary.NumberOfPassedSpecs
+	r.report.Failed = summary.NumberOfFailedSpecs
+	r.report.Skipped = summary.NumberOfSkippedSpecs
+	r.report.Pending = summary.NumberOfPendingSpecs
+
+	if r.convergence != nil {
+		for _, s := range r.convergence.Summary() {
+			r.report.Convergence = append(r.report.Convergence, newJSONConvergence(s))
+		}
+	}
+	if r.scale != nil {
+		for _, result := range r.scale.Results() {
+			scale := JSONScale{
+				Spec:      result.Spec.String(),
+				Endpoints: result.Endpoints,
+				Pairs:     result.Pairs,
+				Sampled:   result.Sampled,
+				Creation:  result.Creation.Seconds(),
+				Policies:  result.Policies,
+			}
+			for _, s := range result.Convergence {
+				scale.Convergence = append(scale.Convergence, newJSONConvergence(s))
+			}
+			r.report.Scale = append(r.report.Scale, scale)
+		}
+	}
+}
+
+func newJSONConvergence(s ConvergenceSummary) JSONConvergence {
+	return JSONConvergence{
+		Kind:   s.Kind,
+		Count:  s.Count,
+		Failed: s.Failed,
+		P50:    s.P50.Seconds(),
+		P90:    s.P90.Seconds(),
+		P99:    s.P99.Seconds(),
+		Max:    s.Max.Seconds(),
+	}
+}
+
+// JSONReporter is a ginkgo reporter writing a machine readable
+// report, which includes the connectivity recorded by every spec,
+// the convergence percentiles of the suite and the results of its
+// scale specs.
+type JSONReporter struct {
+	reportBuilder
+	filename string
+}
+
+// NewJSONReporter returns a JSONReporter writing to filename. The
+// recorders may be nil.
+func NewJSONReporter(filename string, connectivity *ConnectivityRecorder, convergence *ConvergenceRecorder, scale *ScaleRecorder) *JSONReporter {
+	return &JSONReporter{
+		reportBuilder: reportBuilder{
+			connectivity: connectivity,
+			convergence:  convergence,
+			scale:        scale,
+		},
+		filename: filename,
+	}
+}
+
+func (r *JSONReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	r.finish(summary)
+
+	data, err := json.MarshalIndent(r.report, "", "  ")
+	if err != nil {
+		fmt.Printf("Failed to generate JSON report: %v\n", err)
+		return
+	}
+	if err := ioutil.WriteFile(r.filename, data, 0644); err != nil {
+		fmt.Printf("Failed to write JSON report %v: %v\n", r.filename, err)
+	}
+}
+
+// SpecFullText returns the full text of the spec, the same way
+// CurrentGinkgoTestDescription().FullTestText does, so that specs
+// can record data the reporters look up once they complete.
+func SpecFullText(specSummary *types.SpecSummary) string {
+	if len(specSummary.ComponentTexts) < 2 {
+		return strings.Join(specSummary.ComponentTexts, " ")
+	}
+	return strings.Join(specSummary.ComponentTexts[1:], " ")
+}
+
+func newJSONFailure(failure types.SpecFailure) *JSONFailure {
+	return &JSONFailure{
+		Message:  failure.Message,
+		Location: fmt.Sprintf("%v:%v", failure.Location.FileName, failure.Location.LineNumber),
+	}
+}
+
+func specState(state types.SpecState) string {
+	switch state {
+	case types.SpecStatePending:
+		return "pending"
+	case types.SpecStateSkipped:
+		return "skipped"
+	case types.SpecStatePassed:
+		return "passed"
+	case types.SpecStateFailed:
+		return "failed"
+	case types.SpecStatePanicked:
+		return "panicked"
+	case types.SpecStateTimedOut:
+		return "timedout"
+	}
+	return "invalid"
+}
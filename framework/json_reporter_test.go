@@ -0,0 +1,85 @@
+package framework
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+func TestJSONReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "json-reporter")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w1 := Endpoint{Workload: "w1", Namespace: "ns1"}
+	w2 := Endpoint{Workload: "w2", Namespace: "ns2"}
+	connectivity := &ConnectivityRecorder{}
+	connectivity.Record("ProjectIsolation isolates", NewConnectivity("isolation", nil, []ProbeResult{
+		{Pair: Pair{From: w1, To: w2}, Reachable: false, Latency: time.Second},
+	}))
+	convergence := &ConvergenceRecorder{}
+	convergence.Record(ConvergenceSample{Kind: TimeToEnforce, Duration: 2 * time.Second, Converged: true})
+
+	scale := &ScaleRecorder{}
+	scale.Record(ScaleResult{
+		Spec:      ScaleSpec{Projects: 2, Namespaces: 1, Workloads: 1},
+		Endpoints: 2,
+		Pairs:     2,
+		Sampled:   2,
+		Creation:  90 * time.Second,
+		Policies:  4,
+		Convergence: []ConvergenceSummary{
+			{Kind: TimeToEnforce, Count: 1, P50: time.Second, P99: time.Second, Max: time.Second},
+		},
+	})
+
+	filename := filepath.Join(dir, "report.json")
+	r := NewJSONReporter(filename, connectivity, convergence, scale)
+	r.SpecSuiteWillBegin(config.GinkgoConfigType{}, &types.SuiteSummary{SuiteDescription: "Suite"})
+	r.SpecDidComplete(&types.SpecSummary{
+		ComponentTexts: []string{"[Top Level]", "ProjectIsolation", "isolates"},
+		State:          types.SpecStateFailed,
+		Failure:        types.SpecFailure{Message: "boom"},
+	})
+	r.SpecSuiteDidEnd(&types.SuiteSummary{NumberOfFailedSpecs: 1})
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	report := JSONReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if report.Suite != "Suite" || report.Failed != 1 || len(report.Specs) != 1 {
+		t.Fatalf("unexpected report: %s", data)
+	}
+	spec := report.Specs[0]
+	if spec.Name != "ProjectIsolation isolates" || spec.State != "failed" || spec.Failure == nil || spec.Failure.Message != "boom" {
+		t.Errorf("unexpected spec: %+v", spec)
+	}
+	if len(spec.Connectivity) != 1 {
+		t.Fatalf("expected the connectivity of the spec, got %+v", spec.Connectivity)
+	}
+	if allowed, ok := spec.Connectivity[0].Observed.Get("ns1/w1", "ns2/w2"); !ok || allowed {
+		t.Errorf("unexpected observed matrix: %v", spec.Connectivity[0].Observed)
+	}
+	if len(report.Convergence) != 1 || report.Convergence[0].P50 != 2 {
+		t.Errorf("unexpected convergence: %+v", report.Convergence)
+	}
+	if len(report.Scale) != 1 {
+		t.Fatalf("expected the scale result, got %+v", report.Scale)
+	}
+	if s := report.Scale[0]; s.Spec != "2x1x1" || s.Creation != 90 || s.Policies != 4 || len(s.Convergence) != 1 || s.Convergence[0].P50 != 1 {
+		t.Errorf("unexpected scale result: %+v", s)
+	}
+}
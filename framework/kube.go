@@ -0,0 +1,200 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// NetworkPolicyResource is the resource of networking/v1 NetworkPolicies.
+	NetworkPolicyResource = networkingv1.SchemeGroupVersion.WithResource("networkpolicies")
+	// EndpointsResource is the resource of core/v1 Endpoints.
+	EndpointsResource = corev1.SchemeGroupVersion.WithResource("endpoints")
+	// EventResource is the resource of core/v1 Events.
+	EventResource = corev1.SchemeGroupVersion.WithResource("events")
+	// NamespaceResource is the resource of core/v1 Namespaces.
+	NamespaceResource = corev1.SchemeGroupVersion.WithResource("namespaces")
+	// PodResource is the resource of core/v1 Pods.
+	PodResource = corev1.SchemeGroupVersion.WithResource("pods")
+	// NodeResource is the resource of core/v1 Nodes.
+	NodeResource = corev1.SchemeGroupVersion.WithResource("nodes")
+	// ServiceResource is the resource of core/v1 Services.
+	ServiceResource = corev1.SchemeGroupVersion.WithResource("services")
+	// DeploymentResource is the resource of apps/v1 Deployments.
+	DeploymentResource = appsv1.SchemeGroupVersion.WithResource("deployments")
+)
+
+// GetClusterProxyURL returns the URL of the Kubernetes API of the
+// default cluster, proxied by the Rancher server.
+func (rs *RancherServer) GetClusterProxyURL() string {
+	return rs.URL + "/k8s/clusters/" + rs.DefaultCluster.ID
+}
+
+// RESTConfig returns a client-go config for the Kubernetes API of the
+// default cluster, proxied by the Rancher server, authenticated with
+// the credentials of the server.
+func (rs *RancherServer) RESTConfig() *rest.Config {
+	config := &rest.Config{
+		Host: rs.GetClusterProxyURL(),
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: true,
+		},
+		WrapTransport: func(rt http.RoundTripper) http.RoundTripper {
+			return &RetryTransport{Transport: rt, Backoff: rs.Backoff}
+		},
+	}
+	rs.Credentials.configure(config)
+	return config
+}
+
+// KubeClient reads and writes the objects of a Kubernetes cluster
+// with dynamic clients.
+type KubeClient struct {
+	Config *rest.Config
+	Pool   dynamic.ClientPool
+}
+
+// NewKubeClient returns a KubeClient for the cluster of the config.
+func NewKubeClient(config *rest.Config) *KubeClient {
+	return &KubeClient{
+		Config: config,
+		Pool:   dynamic.NewDynamicClientPool(config),
+	}
+}
+
+// ResourceClient returns a dynamic client for the resource in the
+// namespace. The namespace is empty for cluster scoped resources.
+func (c *KubeClient) ResourceClient(resource schema.GroupVersionResource, namespace string) (dynamic.ResourceInterface, error) {
+	client, err := c.Pool.ClientForGroupVersionResource(resource)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client for %v: %v", resource, err)
+	}
+	return client.Resource(&metav1.APIResource{
+		Name:       resource.Resource,
+		Namespaced: namespace != "",
+	}, namespace), nil
+}
+
+// CreateObject creates the typed object as the resource, in the
+// namespace of the object, and updates obj with the created object.
+func (c *KubeClient) CreateObject(resource schema.GroupVersionResource, obj runtime.Object) error {
+	u, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	client, err := c.ResourceClient(resource, u.GetNamespace())
+	if err != nil {
+		return err
+	}
+	created, err := client.Create(u)
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(created.Object, obj)
+}
+
+// GetObject fetches the resource with the name in the namespace into
+// the typed object obj.
+func (c *KubeClient) GetObject(resource schema.GroupVersionResource, namespace, name string, obj runtime.Object) error {
+	client, err := c.ResourceClient(resource, namespace)
+	if err != nil {
+		return err
+	}
+	u, err := client.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+}
+
+// ListObjects lists the resource in the namespace into the typed
+// list obj.
+func (c *KubeClient) ListObjects(resource schema.GroupVersionResource, namespace string, opts metav1.ListOptions, list runtime.Object) error {
+	client, err := c.ResourceClient(resource, namespace)
+	if err != nil {
+		return err
+	}
+	result, err := client.List(opts)
+	if err != nil {
+		return err
+	}
+	u, ok := result.(*unstructured.UnstructuredList)
+	if !ok {
+		return fmt.Errorf("unexpected list type %T", result)
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), list)
+}
+
+// DeleteObject deletes the resource with the name in the namespace.
+func (c *KubeClient) DeleteObject(resource schema.GroupVersionResource, namespace, name string) error {
+	client, err := c.ResourceClient(resource, namespace)
+	if err != nil {
+		return err
+	}
+	return client.Delete(name, &metav1.DeleteOptions{})
+}
+
+// toUnstructured converts the typed object, setting its apiVersion
+// and kind from the client-go scheme when they are not set.
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	if u.GetKind() == "" {
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		u.SetAPIVersion(kinds[0].GroupVersion().String())
+		u.SetKind(kinds[0].Kind)
+	}
+	return u, nil
+}
+
+// CreateNetworkPolicy creates the NetworkPolicy in its namespace.
+func (c *KubeClient) CreateNetworkPolicy(np *networkingv1.NetworkPolicy) error {
+	if err := c.CreateObject(NetworkPolicyResource, np); err != nil {
+		return fmt.Errorf("error creating network policy %v/%v: %v", np.Namespace, np.Name, err)
+	}
+	return nil
+}
+
+// ListNetworkPolicies returns the NetworkPolicies of the namespace.
+func (c *KubeClient) ListNetworkPolicies(namespace string) (*networkingv1.NetworkPolicyList, error) {
+	list := &networkingv1.NetworkPolicyList{}
+	if err := c.ListObjects(NetworkPolicyResource, namespace, metav1.ListOptions{}, list); err != nil {
+		return nil, fmt.Errorf("error listing network policies in namespace %v: %v", namespace, err)
+	}
+	return list, nil
+}
+
+// ListEvents returns the events of the namespace.
+func (c *KubeClient) ListEvents(namespace string) (*corev1.EventList, error) {
+	list := &corev1.EventList{}
+	if err := c.ListObjects(EventResource, namespace, metav1.ListOptions{}, list); err != nil {
+		return nil, fmt.Errorf("error listing events in namespace %v: %v", namespace, err)
+	}
+	return list, nil
+}
+
+// GetEndpoints returns the Endpoints of the service in the namespace.
+func (c *KubeClient) GetEndpoints(namespace, name string) (*corev1.Endpoints, error) {
+	endpoints := &corev1.Endpoints{}
+	if err := c.GetObject(EndpointsResource, namespace, name, endpoints); err != nil {
+		return nil, fmt.Errorf("error fetching endpoints %v/%v: %v", namespace, name, err)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,106 @@
+package framework
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	normantypes "github.com/rancher/norman/types"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateNetworkPolicyThroughClusterProxy(t *testing.T) {
+	var path, auth string
+	var body map[string]interface{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		body["metadata"].(map[string]interface{})["uid"] = "1234"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer server.Close()
+
+	rs := &RancherServer{
+		URL:            server.URL,
+		Credentials:    Credentials{TokenKey: "token-abcde:secret"},
+		DefaultCluster: &rmgmtv3.Cluster{Resource: normantypes.Resource{ID: "c-abcde"}},
+	}
+	rs.KubeClient = NewKubeClient(rs.RESTConfig())
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "ns1"},
+	}
+	if err := rs.CreateNetworkPolicy(np); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if path != "/k8s/clusters/c-abcde/apis/networking.k8s.io/v1/namespaces/ns1/networkpolicies" {
+		t.Errorf("unexpected path %v", path)
+	}
+	if auth != "Bearer token-abcde:secret" {
+		t.Errorf("unexpected authorization %v", auth)
+	}
+	if body["kind"] != "NetworkPolicy" || body["apiVersion"] != "networking.k8s.io/v1" {
+		t.Errorf("expected the type of the object to be set, got %v", body)
+	}
+	if np.UID != "1234" {
+		t.Errorf("expected the policy to be updated with the created object, got %+v", np)
+	}
+}
+
+func TestListNetworkPoliciesOfAllNamespaces(t *testing.T) {
+	var path string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "NetworkPolicyList",
+			"metadata":   map[string]interface{}{},
+			"items": []interface{}{
+				map[string]interface{}{"metadata": map[string]interface{}{"name": "np-default", "namespace": "ns1"}},
+				map[string]interface{}{"metadata": map[string]interface{}{"name": "hn-nodes", "namespace": "ns2"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	rs := &RancherServer{
+		URL:            server.URL,
+		Credentials:    Credentials{TokenKey: "token-abcde:secret"},
+		DefaultCluster: &rmgmtv3.Cluster{Resource: normantypes.Resource{ID: "c-abcde"}},
+	}
+	rs.KubeClient = NewKubeClient(rs.RESTConfig())
+	list, err := rs.ListNetworkPolicies("")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if path != "/k8s/clusters/c-abcde/apis/networking.k8s.io/v1/networkpolicies" {
+		t.Errorf("unexpected path %v", path)
+	}
+	if len(list.Items) != 2 || list.Items[1].Namespace != "ns2" {
+		t.Errorf("unexpected policies %+v", list.Items)
+	}
+}
+
+func TestRESTConfigKeys(t *testing.T) {
+	rs := &RancherServer{
+		URL:            "https://rancher.example.com",
+		Credentials:    Credentials{AccessKey: "token-abcde", SecretKey: "secret"},
+		DefaultCluster: &rmgmtv3.Cluster{Resource: normantypes.Resource{ID: "c-abcde"}},
+	}
+	config := rs.RESTConfig()
+	if config.Host != "https://rancher.example.com/k8s/clusters/c-abcde" {
+		t.Errorf("unexpected host %v", config.Host)
+	}
+	if config.Username != "token-abcde" || config.Password != "secret" || config.BearerToken != "" {
+		t.Errorf("unexpected credentials %+v", config)
+	}
+}
@@ -0,0 +1,185 @@
+package framework
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rancher/test-network-policy/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// WorkloadLabel is set to the name of the probe workload on the
+	// pods created by KubeCluster, which select them by it.
+	WorkloadLabel = "test-network-policy.rancher.io/workload"
+)
+
+// KubeCluster is a Kubernetes cluster reached directly with a
+// kubeconfig, without Rancher. It only runs the tests of native
+// NetworkPolicies, as it has no projects.
+type KubeCluster struct {
+	*KubeClient
+
+	// Transcripts records the commands run by Exec when set.
+	Transcripts *TranscriptRecorder
+}
+
+// NewKubeCluster returns a KubeCluster for the cluster of the config.
+func NewKubeCluster(config *rest.Config) *KubeCluster {
+	return &KubeCluster{
+		KubeClient: NewKubeClient(config),
+	}
+}
+
+// NewKubeClusterFromEnvVars returns a KubeCluster for the kubeconfig
+// file in RANCHER_TEST_KUBECONFIG, using the context in
+// RANCHER_TEST_KUBE_CONTEXT or the current one.
+func NewKubeClusterFromEnvVars() (*KubeCluster, error) {
+	path := os.Getenv("RANCHER_TEST_KUBECONFIG")
+	if path == "" {
+		return nil, fmt.Errorf("RANCHER_TEST_KUBECONFIG not specified")
+	}
+	config, err := LoadKubeconfig(path, os.Getenv("RANCHER_TEST_KUBE_CONTEXT"))
+	if err != nil {
+		return nil, err
+	}
+	return NewKubeCluster(config), nil
+}
+
+// Exec runs the command in the container of the pod through the
+// Kubernetes API and returns its output. The command is recorded in
+// Transcripts if set.
+func (c *KubeCluster) Exec(namespace, pod, container, command string) (string, error) {
+	tlsConfig, err := rest.TLSConfigFor(c.Config)
+	if err != nil {
+		return "", err
+	}
+	h := http.Header{}
+	if c.Config.BearerToken != "" {
+		h.Set("Authorization", "Bearer "+c.Config.BearerToken)
+	} else if c.Config.Username != "" {
+		h.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.Config.Username+":"+c.Config.Password)))
+	}
+
+	wsURL := utils.GetKubeWSURL(c.Config.Host, namespace, pod, container, command)
+	return recordExec(c.Transcripts, namespace, pod, container, command, func() (string, error) {
+		return utils.RunExecCommandWithHeader(wsURL, h, tlsConfig)
+	})
+}
+
+// CreateNamespace creates a namespace labelled with TestResourceLabel
+// in addition to the given labels.
+func (c *KubeCluster) CreateNamespace(name string, labels map[string]string) error {
+	l := TestResourceLabels()
+	for k, v := range labels {
+		l[k] = v
+	}
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: l},
+	}
+	if err := c.CreateObject(NamespaceResource, ns); err != nil {
+		return fmt.Errorf("error creating namespace %v: %v", name, err)
+	}
+	return nil
+}
+
+// DeleteNamespace deletes the namespace and everything in it.
+func (c *KubeCluster) DeleteNamespace(name string) error {
+	if err := c.DeleteObject(NamespaceResource, "", name); err != nil {
+		return fmt.Errorf("error deleting namespace %v: %v", name, err)
+	}
+	return nil
+}
+
+// CreateProbeWorkload creates a single pod deployment running the
+// ProbeImage, pinned to the node if not empty, and a service of the
+// same name in front of it, then waits for the service to be ready and
+// returns the endpoint of the pod.
+func (c *KubeCluster) CreateProbeWorkload(namespace, name string, workloadLabels map[string]string, node string) (Endpoint, error) {
+	podLabels := map[string]string{WorkloadLabel: name}
+	for k, v := range workloadLabels {
+		podLabels[k] = v
+	}
+	selector := map[string]string{WorkloadLabel: name}
+	replicas := int32(1)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  name,
+							Image: ProbeImage(),
+							Stdin: true,
+							TTY:   true,
+							Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 80}},
+
+							ReadinessProbe: newKubeReadinessProbe(),
+						},
+					},
+				},
+			},
+		},
+	}
+	if node != "" {
+		deployment.Spec.Template.Spec.Affinity = newNodeAffinity(node)
+	}
+	if err := c.CreateObject(DeploymentResource, deployment); err != nil {
+		return Endpoint{}, fmt.Errorf("error creating deployment %v/%v: %v", namespace, name, err)
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.ServiceSpec{
+			Selector: selector,
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromInt(80)}},
+		},
+	}
+	if err := c.CreateObject(ServiceResource, service); err != nil {
+		return Endpoint{}, fmt.Errorf("error creating service %v/%v: %v", namespace, name, err)
+	}
+
+	if err := c.WaitForServiceReady(namespace, name, DefaultWaitTimeout); err != nil {
+		return Endpoint{}, err
+	}
+
+	var pod *corev1.Pod
+	err := wait.PollImmediate(waitInterval, DefaultWaitTimeout, func() (bool, error) {
+		pods := &corev1.PodList{}
+		opts := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(selector).String()}
+		if err := c.ListObjects(PodResource, namespace, opts, pods); err != nil {
+			return false, nil
+		}
+		for i := range pods.Items {
+			if podReady(&pods.Items[i]) {
+				pod = &pods.Items[i]
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("error waiting for the pod of workload %v/%v to be ready: %v", namespace, name, err)
+	}
+
+	return Endpoint{
+		Workload:  name,
+		Namespace: namespace,
+		Pod:       pod.Name,
+		Container: name,
+		Labels:    pod.Labels,
+	}, nil
+}
@@ -0,0 +1,128 @@
+package framework
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/client-go/rest"
+)
+
+// kubeconfig is the subset of the kubeconfig file format needed to
+// reach a cluster with static credentials.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData []byte `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			ClientCertificate     string      `json:"client-certificate"`
+			ClientCertificateData []byte      `json:"client-certificate-data"`
+			ClientKey             string      `json:"client-key"`
+			ClientKeyData         []byte      `json:"client-key-data"`
+			Token                 string      `json:"token"`
+			TokenFile             string      `json:"tokenFile"`
+			Username              string      `json:"username"`
+			Password              string      `json:"password"`
+			Exec                  interface{} `json:"exec"`
+			AuthProvider          interface{} `json:"auth-provider"`
+		} `json:"user"`
+	} `json:"users"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+}
+
+// LoadKubeconfig returns the client-go config for the context of the
+// kubeconfig file, or its current context if context is empty. Only
+// static credentials are supported: client certificates, tokens and
+// basic auth.
+func LoadKubeconfig(path, context string) (*rest.Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kc := &kubeconfig{}
+	if err := yaml.Unmarshal(data, kc); err != nil {
+		return nil, fmt.Errorf("error parsing kubeconfig %v: %v", path, err)
+	}
+
+	if context == "" {
+		context = kc.CurrentContext
+	}
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == context {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("context %q not found in kubeconfig %v", context, path)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+
+	config := &rest.Config{}
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name == clusterName {
+			found = true
+			config.Host = c.Cluster.Server
+			config.TLSClientConfig.CAFile = resolve(c.Cluster.CertificateAuthority)
+			config.TLSClientConfig.CAData = c.Cluster.CertificateAuthorityData
+			config.TLSClientConfig.Insecure = c.Cluster.InsecureSkipTLSVerify
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("cluster %q of context %q not found in kubeconfig %v", clusterName, context, path)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		if u.User.Exec != nil || u.User.AuthProvider != nil {
+			return nil, fmt.Errorf("user %q of kubeconfig %v uses an unsupported exec or auth provider plugin", userName, path)
+		}
+		config.TLSClientConfig.CertFile = resolve(u.User.ClientCertificate)
+		config.TLSClientConfig.CertData = u.User.ClientCertificateData
+		config.TLSClientConfig.KeyFile = resolve(u.User.ClientKey)
+		config.TLSClientConfig.KeyData = u.User.ClientKeyData
+		config.BearerToken = u.User.Token
+		config.Username = u.User.Username
+		config.Password = u.User.Password
+		if u.User.TokenFile != "" && config.BearerToken == "" {
+			token, err := ioutil.ReadFile(resolve(u.User.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("error reading token file of user %q: %v", userName, err)
+			}
+			config.BearerToken = strings.TrimSpace(string(token))
+		}
+	}
+
+	if err := rest.LoadTLSFiles(config); err != nil {
+		return nil, fmt.Errorf("error loading TLS files of kubeconfig %v: %v", path, err)
+	}
+	return config, nil
+}
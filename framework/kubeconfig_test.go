@@ -0,0 +1,75 @@
+package framework
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com:6443
+    certificate-authority-data: Y2EtZGF0YQ==
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com:6443
+    insecure-skip-tls-verify: true
+users:
+- name: dev-user
+  user:
+    tokenFile: token
+- name: prod-user
+  user:
+    username: admin
+    password: secret
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+`
+
+func TestLoadKubeconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("dev-token\n"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	config, err := LoadKubeconfig(path, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if config.Host != "https://dev.example.com:6443" || config.BearerToken != "dev-token" || string(config.CAData) != "ca-data" {
+		t.Errorf("unexpected config for the current context: %+v", config)
+	}
+
+	config, err = LoadKubeconfig(path, "prod")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if config.Host != "https://prod.example.com:6443" || !config.Insecure || config.Username != "admin" || config.Password != "secret" {
+		t.Errorf("unexpected config for the prod context: %+v", config)
+	}
+
+	if _, err := LoadKubeconfig(path, "staging"); err == nil {
+		t.Errorf("expected an error for an unknown context")
+	}
+}
@@ -0,0 +1,152 @@
+package framework
+
+import (
+	"fmt"
+
+	normantypes "github.com/rancher/norman/types"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+)
+
+const (
+	// TestResourceLabel is set on every project created by the tests,
+	// so that leftovers can be found and swept.
+	TestResourceLabel = "test-network-policy.rancher.io/test"
+)
+
+// TestResourceLabels returns the labels to set on resources
+// created by the tests.
+func TestResourceLabels() map[string]string {
+	return map[string]string{TestResourceLabel: "true"}
+}
+
+// ListTestProjects returns the projects of the default cluster
+// which carry TestResourceLabel.
+func (rs *RancherServer) ListTestProjects() ([]rmgmtv3.Project, error) {
+	all, err := rs.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	var projects []rmgmtv3.Project
+	for _, p := range all {
+		if p.Labels[TestResourceLabel] == "true" {
+			projects = append(projects, p)
+		}
+	}
+	return projects, nil
+}
+
+// ListProjects returns all the projects of the default cluster.
+func (rs *RancherServer) ListProjects() ([]rmgmtv3.Project, error) {
+	collection, err := rs.ManagementClient.Project.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"clusterId": rs.DefaultCluster.ID,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing projects: %v", err)
+	}
+
+	var projects []rmgmtv3.Project
+	for collection != nil {
+		projects = append(projects, collection.Data...)
+		collection, err = collection.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error listing projects: %v", err)
+		}
+	}
+	return projects, nil
+}
+
+// GetNamespaceProjectClient returns a client for the project the
+// namespace belongs to.
+func (rs *RancherServer) GetNamespaceProjectClient(namespace string) (*rprojectv3.Client, error) {
+	ns, err := rs.DefaultClusterClient.Namespace.ByID(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching namespace %v: %v", namespace, err)
+	}
+	if ns.ProjectID == "" {
+		return nil, fmt.Errorf("namespace %v is not in a project", namespace)
+	}
+	return rs.GetProjectClientByID(ns.ProjectID)
+}
+
+// GetEndpoint returns the endpoint of the workload in the namespace.
+func (rs *RancherServer) GetEndpoint(namespace, workload string) (Endpoint, error) {
+	client, err := rs.GetNamespaceProjectClient(namespace)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	collection, err := client.Workload.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"namespaceId": namespace,
+			"name":        workload,
+		},
+	})
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("error fetching workload %v/%v: %v", namespace, workload, err)
+	}
+	if len(collection.Data) == 0 {
+		return Endpoint{}, fmt.Errorf("workload %v/%v not found", namespace, workload)
+	}
+	return GetWorkloadEndpoint(client, &collection.Data[0])
+}
+
+// ListNamespaceEndpoints returns the endpoints of all the workloads
+// in the namespace.
+func (rs *RancherServer) ListNamespaceEndpoints(namespace string) ([]Endpoint, error) {
+	client, err := rs.GetNamespaceProjectClient(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := client.Workload.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"namespaceId": namespace,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing workloads in namespace %v: %v", namespace, err)
+	}
+
+	var endpoints []Endpoint
+	for collection != nil {
+		for i := range collection.Data {
+			e, err := GetWorkloadEndpoint(client, &collection.Data[i])
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, e)
+		}
+		collection, err = collection.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error listing workloads in namespace %v: %v", namespace, err)
+		}
+	}
+	return endpoints, nil
+}
+
+// ListProjectNamespaces returns the names of the namespaces of the project.
+func (rs *RancherServer) ListProjectNamespaces(projectID string) ([]string, error) {
+	collection, err := rs.DefaultClusterClient.Namespace.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"projectId": projectID,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces of project %v: %v", projectID, err)
+	}
+
+	var names []string
+	for collection != nil {
+		for _, ns := range collection.Data {
+			names = append(names, ns.Name)
+		}
+		collection, err = collection.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error listing namespaces of project %v: %v", projectID, err)
+		}
+	}
+	return names, nil
+}
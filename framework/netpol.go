@@ -0,0 +1,115 @@
+package framework
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/rancher/test-network-policy/policy"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// PolicyReport holds the NetworkPolicies of a set of namespaces and
+// their differences from the policies Rancher is expected to
+// generate in them.
+type PolicyReport struct {
+	Policies []networkingv1.NetworkPolicy
+	Diffs    []policy.PolicyDiff
+}
+
+func (r *PolicyReport) String() string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("NetworkPolicies:\n")
+	buf.WriteString(policy.RenderAll(r.Policies))
+	if len(r.Diffs) == 0 {
+		buf.WriteString("\nThe Rancher generated NetworkPolicies are as expected.\n")
+		return buf.String()
+	}
+	buf.WriteString("\nDifferences from the expected Rancher generated NetworkPolicies:\n")
+	for _, d := range r.Diffs {
+		buf.WriteString(d.String())
+	}
+	return buf.String()
+}
+
+// ProjectPolicyReport fetches the NetworkPolicies of all the
+// namespaces of the projects and compares them with the project
+// isolation policy Rancher is expected to generate in each of them.
+func (rs *RancherServer) ProjectPolicyReport(projectIDs []string) (*PolicyReport, error) {
+	report := &PolicyReport{}
+	namespaceProjects := map[string]string{}
+	for _, projectID := range projectIDs {
+		namespaces, err := rs.ListProjectNamespaces(projectID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range namespaces {
+			namespaceProjects[ns] = projectID
+			list, err := rs.ListNetworkPolicies(ns)
+			if err != nil {
+				return nil, err
+			}
+			report.Policies = append(report.Policies, list.Items...)
+		}
+	}
+	report.Diffs = policy.DiffPolicies(policy.ProjectPolicies(namespaceProjects), report.Policies)
+	return report, nil
+}
+
+// OrphanedPolicies returns the Rancher generated policies, across all
+// the namespaces of the default cluster, whose namespace is not in an
+// existing project or is in another project than the one they allow.
+// The policies of terminating namespaces are ignored.
+func (rs *RancherServer) OrphanedPolicies() ([]policy.OrphanedPolicy, error) {
+	projects, err := rs.ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	var projectLabels []string
+	for _, p := range projects {
+		projectLabels = append(projectLabels, policy.ProjectLabelValue(p.ID))
+	}
+
+	nsList := &corev1.NamespaceList{}
+	if err := rs.ListObjects(NamespaceResource, "", metav1.ListOptions{}, nsList); err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %v", err)
+	}
+	var namespaces []policy.Namespace
+	for _, ns := range nsList.Items {
+		if ns.DeletionTimestamp == nil {
+			namespaces = append(namespaces, policy.Namespace{Name: ns.Name, Labels: ns.Labels})
+		}
+	}
+
+	// An empty namespace lists the policies of all the namespaces.
+	policies, err := rs.ListNetworkPolicies("")
+	if err != nil {
+		return nil, err
+	}
+	return policy.OrphanedPolicies(namespaces, projectLabels, policies.Items), nil
+}
+
+// WaitForNoOrphanedPolicies waits for Rancher to remove or update the
+// orphaned policies of the cluster, and returns the ones still
+// orphaned after timeout.
+func (rs *RancherServer) WaitForNoOrphanedPolicies(timeout time.Duration) ([]policy.OrphanedPolicy, error) {
+	var orphans []policy.OrphanedPolicy
+	var lastErr error
+	listed := false
+	err := wait.PollImmediate(waitInterval, timeout, func() (bool, error) {
+		o, err := rs.OrphanedPolicies()
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		orphans, listed = o, true
+		return len(orphans) == 0, nil
+	})
+	if err != nil && !listed {
+		return nil, lastErr
+	}
+	return orphans, nil
+}
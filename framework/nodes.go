@@ -0,0 +1,183 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	normantypes "github.com/rancher/norman/types"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HostnameLabel is the label of nodes holding their name.
+const HostnameLabel = "kubernetes.io/hostname"
+
+// Placement tells which nodes the workloads of a test are pinned to,
+// since CNIs enforce policies differently on traffic within a node and
+// across nodes.
+type Placement string
+
+const (
+	// AnyNode leaves the workloads wherever the scheduler puts them.
+	AnyNode Placement = "any-node"
+	// SameNode pins all the workloads to the same node.
+	SameNode Placement = "same-node"
+	// CrossNode spreads the workloads over the nodes, one per node
+	// when there are enough of them.
+	CrossNode Placement = "cross-node"
+)
+
+// Placements lists the placements which pin workloads.
+var Placements = []Placement{SameNode, CrossNode}
+
+// ParsePlacement returns the placement with the name.
+func ParsePlacement(name string) (Placement, error) {
+	for _, p := range []Placement{AnyNode, SameNode, CrossNode} {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown placement %q, expected %v, %v or %v", name, AnyNode, SameNode, CrossNode)
+}
+
+// PlacementsFromEnvVars returns the placements listed, separated by
+// commas, in RANCHER_TEST_PLACEMENTS, AnyNode when not set.
+func PlacementsFromEnvVars() ([]Placement, error) {
+	v := os.Getenv("RANCHER_TEST_PLACEMENTS")
+	if v == "" {
+		return []Placement{AnyNode}, nil
+	}
+	var placements []Placement
+	for _, name := range strings.Split(v, ",") {
+		p, err := ParsePlacement(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RANCHER_TEST_PLACEMENTS: %v", err)
+		}
+		placements = append(placements, p)
+	}
+	return placements, nil
+}
+
+// MinNodes returns the number of schedulable nodes the placement needs.
+func (p Placement) MinNodes() int {
+	if p == CrossNode {
+		return 2
+	}
+	return 1
+}
+
+// Node returns the node, among the schedulable ones, which the i-th
+// workload of a test is pinned to, or an empty string for AnyNode.
+func (p Placement) Node(nodes []string, i int) string {
+	switch {
+	case len(nodes) == 0:
+		return ""
+	case p == SameNode:
+		return nodes[0]
+	case p == CrossNode:
+		return nodes[i%len(nodes)]
+	default:
+		return ""
+	}
+}
+
+// Includes returns whether the endpoints of the pair are placed as the
+// placement says: on the same node for SameNode, and on different nodes
+// for CrossNode, which puts some pairs on the same node when there are
+// fewer nodes than workloads. Every pair is included for AnyNode.
+func (p Placement) Includes(pair Pair) bool {
+	switch p {
+	case SameNode:
+		return pair.SameNode()
+	case CrossNode:
+		return pair.From.Node != "" && pair.To.Node != "" && !pair.SameNode()
+	default:
+		return true
+	}
+}
+
+// ListSchedulableNodes returns the names of the nodes which are ready
+// and accept pods, in order.
+func (c *KubeClient) ListSchedulableNodes() ([]string, error) {
+	nodes := &corev1.NodeList{}
+	if err := c.ListObjects(NodeResource, "", metav1.ListOptions{}, nodes); err != nil {
+		return nil, fmt.Errorf("error listing nodes: %v", err)
+	}
+	var names []string
+	for i := range nodes.Items {
+		if nodeSchedulable(&nodes.Items[i]) {
+			names = append(names, nodes.Items[i].Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func nodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, t := range node.Spec.Taints {
+		if t.Effect == corev1.TaintEffectNoSchedule || t.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PinWorkload schedules the pods of the workload on the Rancher node
+// with the ID.
+func PinWorkload(w *rprojectv3.Workload, nodeID string) {
+	if w.Scheduling == nil {
+		w.Scheduling = &rprojectv3.Scheduling{}
+	}
+	w.Scheduling.Node = &rprojectv3.NodeScheduling{NodeId: nodeID}
+}
+
+// GetNodeID returns the ID of the Rancher node of the default cluster
+// with the name.
+func (rs *RancherServer) GetNodeID(name string) (string, error) {
+	collection, err := rs.ManagementClient.Node.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"clusterId": rs.DefaultCluster.ID,
+			"nodeName":  name,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error fetching node %v: %v", name, err)
+	}
+	if len(collection.Data) == 0 {
+		return "", fmt.Errorf("node %v not found in cluster %v", name, rs.DefaultCluster.ID)
+	}
+	return collection.Data[0].ID, nil
+}
+
+// newNodeAffinity returns the affinity of pods which must run on the
+// node.
+func newNodeAffinity(node string) *corev1.Affinity {
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{
+								Key:      HostnameLabel,
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{node},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
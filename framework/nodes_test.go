@@ -0,0 +1,90 @@
+package framework
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPlacementNode(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3"}
+	tests := []struct {
+		placement Placement
+		want      []string
+	}{
+		{AnyNode, []string{"", "", "", ""}},
+		{SameNode, []string{"node1", "node1", "node1", "node1"}},
+		{CrossNode, []string{"node1", "node2", "node3", "node1"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for i := 0; i < 4; i++ {
+			got = append(got, test.placement.Node(nodes, i))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: nodes = %v, want %v", test.placement, got, test.want)
+		}
+	}
+}
+
+func TestPlacementIncludes(t *testing.T) {
+	pair := func(from, to string) Pair {
+		return Pair{From: Endpoint{Workload: "w1", Node: from}, To: Endpoint{Workload: "w2", Node: to}}
+	}
+	tests := []struct {
+		pair                         Pair
+		anyNode, sameNode, crossNode bool
+	}{
+		{pair("node1", "node1"), true, true, false},
+		{pair("node1", "node2"), true, false, true},
+		{pair("", "node2"), true, false, false},
+		{pair("", ""), true, false, false},
+	}
+	for _, test := range tests {
+		if AnyNode.Includes(test.pair) != test.anyNode || SameNode.Includes(test.pair) != test.sameNode || CrossNode.Includes(test.pair) != test.crossNode {
+			t.Errorf("%v to %v: any %v, same %v, cross %v", test.pair.From.Node, test.pair.To.Node,
+				AnyNode.Includes(test.pair), SameNode.Includes(test.pair), CrossNode.Includes(test.pair))
+		}
+	}
+}
+
+func TestPlacementsFromEnvVars(t *testing.T) {
+	defer os.Unsetenv("RANCHER_TEST_PLACEMENTS")
+	tests := []struct {
+		value string
+		want  []Placement
+		err   bool
+	}{
+		{"", []Placement{AnyNode}, false},
+		{"same-node, cross-node", []Placement{SameNode, CrossNode}, false},
+		{"same-node,other-node", nil, true},
+	}
+	for _, test := range tests {
+		os.Setenv("RANCHER_TEST_PLACEMENTS", test.value)
+		got, err := PlacementsFromEnvVars()
+		if (err != nil) != test.err || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("PlacementsFromEnvVars() with %q = %v, %v", test.value, got, err)
+		}
+	}
+}
+
+func TestNodeSchedulable(t *testing.T) {
+	ready := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}}
+	tests := []struct {
+		node corev1.Node
+		want bool
+	}{
+		{corev1.Node{Status: ready}, true},
+		{corev1.Node{}, false},
+		{corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}, Status: ready}, false},
+		{corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "node-role.kubernetes.io/controlplane", Effect: corev1.TaintEffectNoSchedule}}}, Status: ready}, false},
+		{corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "example.com/slow", Effect: corev1.TaintEffectPreferNoSchedule}}}, Status: ready}, true},
+	}
+	for i, test := range tests {
+		if got := nodeSchedulable(&test.node); got != test.want {
+			t.Errorf("%d: schedulable = %v, want %v", i, got, test.want)
+		}
+	}
+}
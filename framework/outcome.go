@@ -0,0 +1,126 @@
+package framework
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/test-network-policy/agent"
+)
+
+// ProbeOutcome classifies the result of a probe.
+type ProbeOutcome string
+
+const (
+	// Success is a probe answered by the destination pod.
+	Success ProbeOutcome = "success"
+	// Timeout is a probe which got no answer, as when it is dropped.
+	Timeout ProbeOutcome = "timeout"
+	// Refused is a probe whose connection was rejected.
+	Refused ProbeOutcome = "refused"
+	// DNSFailure is a probe whose destination could not be resolved.
+	DNSFailure ProbeOutcome = "dns-failure"
+	// ExecError is a probe whose command could not be run in the
+	// source pod.
+	ExecError ProbeOutcome = "exec-error"
+	// InfraError is a probe which failed in any other way, which
+	// tells nothing about network policies.
+	InfraError ProbeOutcome = "infra-error"
+)
+
+// Blocked tells whether the outcome is one a network policy causes:
+// the probe was dropped or rejected.
+func (o ProbeOutcome) Blocked() bool {
+	return o == Timeout || o == Refused
+}
+
+// Conclusive tells whether the outcome tells the reachability of the
+// destination, that is whether it succeeded or was blocked. Other
+// outcomes are failures of the probe itself.
+func (o ProbeOutcome) Conclusive() bool {
+	return o == Success || o.Blocked()
+}
+
+var (
+	// exitCodeCause is how the Kubernetes exec status reports the
+	// exit code of the command.
+	exitCodeCause = regexp.MustCompile(`"reason":"ExitCode","message":"(\d+)"`)
+	// exitCodeMessage is how the exec status message, which is the
+	// only thing the Rancher proxy relays, reports it.
+	exitCodeMessage = regexp.MustCompile(`non-zero exit code:[^\d\n]*(\d+)`)
+)
+
+// curlOutcomes are the outcomes of the curl exit codes.
+var curlOutcomes = map[int]ProbeOutcome{
+	6:   DNSFailure, // could not resolve host
+	7:   Refused,    // failed to connect
+	28:  Timeout,    // operation timed out
+	56:  Refused,    // failure receiving data, as when the connection is reset
+	126: ExecError,  // command not executable
+	127: ExecError,  // command not found
+}
+
+// execErrors are found in the output of commands which could not be
+// run in the container.
+var execErrors = []string{
+	"executable file not found",
+	"OCI runtime exec failed",
+	"container not found",
+	"unable to upgrade connection",
+}
+
+// CurlOutcome classifies the output of curl run in a container to
+// request the pod: Success when the pod replied, or the outcome of the
+// exit code of curl.
+func CurlOutcome(output, pod string) ProbeOutcome {
+	code, ok := exitCode(output)
+	if !ok {
+		for _, e := range execErrors {
+			if strings.Contains(output, e) {
+				return ExecError
+			}
+		}
+		if pod != "" && strings.Contains(output, pod) {
+			return Success
+		}
+		return InfraError
+	}
+	if outcome, ok := curlOutcomes[code]; ok {
+		return outcome
+	}
+	return InfraError
+}
+
+// exitCode returns the exit code of the command in the output of an
+// exec, if it failed.
+func exitCode(output string) (int, bool) {
+	m := exitCodeCause.FindStringSubmatch(output)
+	if m == nil {
+		m = exitCodeMessage.FindStringSubmatch(output)
+	}
+	if m == nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(m[1])
+	return code, err == nil
+}
+
+// agentOutcome classifies the result of the probe agent requesting the
+// pod.
+func agentOutcome(r agent.Result, pod string) ProbeOutcome {
+	switch r.Outcome {
+	case agent.OutcomeSuccess:
+		if r.Connected && r.Response != nil && r.Response.Pod == pod {
+			return Success
+		}
+		return InfraError
+	case agent.OutcomeTimeout:
+		return Timeout
+	case agent.OutcomeRefused:
+		return Refused
+	case agent.OutcomeDNSFailure:
+		return DNSFailure
+	default:
+		return InfraError
+	}
+}
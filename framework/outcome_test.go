@@ -0,0 +1,40 @@
+package framework
+
+import (
+	"testing"
+)
+
+func TestCurlOutcome(t *testing.T) {
+	tests := []struct {
+		output string
+		want   ProbeOutcome
+	}{
+		{"<html>Hello from w2-fghij</html>", Success},
+		{"command terminated with non-zero exit code: Error executing in Docker Container: 28", Timeout},
+		{"command terminated with non-zero exit code: Error executing in Docker Container: 7", Refused},
+		{"command terminated with non-zero exit code: exit status 6", DNSFailure},
+		{`{"metadata":{},"status":"Failure","message":"command terminated with non-zero exit code: error executing command [curl], exit code 56","reason":"NonZeroExitCode","details":{"causes":[{"reason":"ExitCode","message":"56"}]}}`, Refused},
+		{"command terminated with non-zero exit code: Error executing in Docker Container: 127", ExecError},
+		{"command terminated with non-zero exit code: Error executing in Docker Container: 35", InfraError},
+		{`OCI runtime exec failed: exec failed: exec: "curl": executable file not found in $PATH`, ExecError},
+		{"<html>Hello from w3-klmno</html>", InfraError},
+		{"", InfraError},
+	}
+	for _, test := range tests {
+		if got := CurlOutcome(test.output, "w2-fghij"); got != test.want {
+			t.Errorf("CurlOutcome(%q) = %v, want %v", test.output, got, test.want)
+		}
+	}
+}
+
+func TestProbeOutcome(t *testing.T) {
+	for _, o := range []ProbeOutcome{Success, Timeout, Refused, DNSFailure, ExecError, InfraError} {
+		blocked := o == Timeout || o == Refused
+		if o.Blocked() != blocked {
+			t.Errorf("%v: blocked = %v", o, o.Blocked())
+		}
+		if o.Conclusive() != (blocked || o == Success) {
+			t.Errorf("%v: conclusive = %v", o, o.Conclusive())
+		}
+	}
+}
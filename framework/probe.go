@@ -0,0 +1,293 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	normantypes "github.com/rancher/norman/types"
+	"github.com/rancher/test-network-policy/agent"
+	"github.com/rancher/test-network-policy/policy"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+)
+
+const (
+	// DefaultProbeTimeout is the time a single curl probe is
+	// allowed to take before the destination is considered blocked.
+	DefaultProbeTimeout = 5 * time.Second
+
+	// DefaultProbeParallelism is the number of probes a Prober runs
+	// at once, each of which holds an exec websocket to the Rancher
+	// proxy.
+	DefaultProbeParallelism = 20
+)
+
+// AgentImage is the image of the probe agent of cmd/probe-agent, set by
+// RANCHER_TEST_PROBE_AGENT_IMAGE. When set, the probe workloads run it
+// instead of DefaultImage, and the probes parse its JSON results.
+var AgentImage = os.Getenv("RANCHER_TEST_PROBE_AGENT_IMAGE")
+
+// ProbeImage returns the image of the probe workloads.
+func ProbeImage() string {
+	if AgentImage != "" {
+		return AgentImage
+	}
+	return DefaultImage
+}
+
+// Endpoint is a single pod that connectivity probes are
+// run from or sent to. PodIP, ClusterIP and Node are only set once
+// the endpoint is resolved. PerNode is set for the pods of a workload
+// running on every node, which are then told apart by their node.
+type Endpoint struct {
+	Project   string
+	Workload  string
+	Namespace string
+	Pod       string
+	Container string
+	Labels    map[string]string
+	PodIP     string
+	ClusterIP string
+	Node      string
+	PerNode   bool
+}
+
+// Host returns the service DNS name of the endpoint's workload.
+func (e Endpoint) Host() string {
+	return e.Workload + "." + e.Namespace
+}
+
+// Name returns the name of the workload, followed by the node of the
+// pod for PerNode endpoints.
+func (e Endpoint) Name() string {
+	if e.PerNode {
+		return e.Workload + "@" + e.Node
+	}
+	return e.Workload
+}
+
+func (e Endpoint) String() string {
+	return e.Namespace + "/" + e.Name()
+}
+
+// PolicyPod returns the endpoint as seen by the policy evaluator.
+// Its key in a policy.Matrix is the same as the endpoint's String.
+func (e Endpoint) PolicyPod() policy.Pod {
+	return policy.Pod{
+		Namespace: e.Namespace,
+		Name:      e.Name(),
+		Labels:    e.Labels,
+	}
+}
+
+// Pair is a directed connectivity check from one endpoint to another,
+// through the kind of address of the destination in Via, ViaDNS when
+// not set.
+type Pair struct {
+	From Endpoint
+	To   Endpoint
+	Via  Via
+}
+
+// SameNode tells whether both endpoints of the pair are known to run
+// on the same node.
+func (p Pair) SameNode() bool {
+	return p.From.Node != "" && p.From.Node == p.To.Node
+}
+
+func (p Pair) String() string {
+	if p.Via == "" || p.Via == ViaDNS {
+		return p.From.String() + " -> " + p.To.String()
+	}
+	return p.From.String() + " -> " + p.To.String() + " via " + string(p.Via)
+}
+
+// ProbeResult holds the outcome of a single probe. Reachable is set
+// when the Outcome is Success.
+type ProbeResult struct {
+	Pair      Pair
+	Reachable bool
+	Outcome   ProbeOutcome
+	Output    string
+	Latency   time.Duration
+	Err       error
+}
+
+// Prober runs connectivity probes by exec'ing curl, or the probe
+// agent, inside the source pod with the Executor.
+type Prober struct {
+	Executor Executor
+	Timeout  time.Duration
+
+	// Agent tells whether the probe workloads run the probe agent,
+	// which then runs the probes instead of curl.
+	Agent bool
+
+	// Parallelism is the number of probes ProbeAll runs at once, all
+	// of them when it is not positive.
+	Parallelism int
+}
+
+// NewProber returns a Prober using DefaultProbeTimeout and
+// DefaultProbeParallelism, which uses the probe agent when AgentImage
+// is set.
+func NewProber(e Executor) *Prober {
+	return &Prober{
+		Executor:    e,
+		Timeout:     DefaultProbeTimeout,
+		Agent:       AgentImage != "",
+		Parallelism: DefaultProbeParallelism,
+	}
+}
+
+// Probe requests the destination of the pair over HTTP from its source
+// pod. The destination is reachable when it replies with the name of
+// the destination pod; otherwise the Outcome tells why.
+func (p *Prober) Probe(pair Pair) ProbeResult {
+	host := pair.To.Address(pair.Via)
+	if host == "" {
+		return ProbeResult{
+			Pair:    pair,
+			Outcome: InfraError,
+			Err:     fmt.Errorf("no %v address for %v, the endpoint is not resolved", pair.Via, pair.To),
+		}
+	}
+	command := fmt.Sprintf("curl --max-time %d -s http://%s", int(p.Timeout/time.Second), host)
+	if p.Agent {
+		command = fmt.Sprintf("probe-agent probe --protocol %v --host %v --timeout %v", agent.HTTP, host, p.Timeout)
+	}
+
+	start := time.Now()
+	output, err := p.Executor.Exec(pair.From.Namespace, pair.From.Pod, pair.From.Container, command)
+	result := ProbeResult{
+		Pair:    pair,
+		Output:  output,
+		Latency: time.Since(start),
+		Err:     err,
+	}
+	if err != nil {
+		result.Outcome = ExecError
+		return result
+	}
+
+	if !p.Agent {
+		result.Outcome = CurlOutcome(output, pair.To.Pod)
+	} else if r, err := agent.ParseResult(output); err == nil {
+		result.Outcome = agentOutcome(r, pair.To.Pod)
+	} else {
+		result.Outcome = CurlOutcome(output, "")
+		if result.Outcome == InfraError {
+			result.Err = err
+		}
+	}
+	result.Reachable = result.Outcome == Success
+	return result
+}
+
+// ProbeAll probes the pairs concurrently, Parallelism at a time, and
+// returns the results in the same order.
+func (p *Prober) ProbeAll(pairs []Pair) []ProbeResult {
+	results := make([]ProbeResult, len(pairs))
+	workers := p.Parallelism
+	if workers <= 0 || workers > len(pairs) {
+		workers = len(pairs)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = p.Probe(pairs[i])
+			}
+		}()
+	}
+	for i := range pairs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// ProbeMatrix probes every ordered pair of distinct endpoints and
+// returns the observed reachability along with the probe results.
+func (p *Prober) ProbeMatrix(endpoints []Endpoint) (policy.Matrix, []ProbeResult) {
+	return p.ProbeMatrixVia(endpoints, ViaDNS)
+}
+
+// ProbeMatrixVia is ProbeMatrix with the destinations probed through
+// their addresses of the kind, which must be resolved for ViaPodIP and
+// ViaClusterIP.
+func (p *Prober) ProbeMatrixVia(endpoints []Endpoint, via Via) (policy.Matrix, []ProbeResult) {
+	var pairs []Pair
+	for _, from := range endpoints {
+		for _, to := range endpoints {
+			if from.String() != to.String() {
+				pairs = append(pairs, Pair{From: from, To: to, Via: via})
+			}
+		}
+	}
+
+	return p.ProbePairs(pairs)
+}
+
+// ProbePairs probes the pairs and returns the observed reachability
+// along with the probe results.
+func (p *Prober) ProbePairs(pairs []Pair) (policy.Matrix, []ProbeResult) {
+	observed := policy.Matrix{}
+	results := p.ProbeAll(pairs)
+	for _, r := range results {
+		observed.Set(r.Pair.From.String(), r.Pair.To.String(), r.Reachable)
+	}
+	return observed, results
+}
+
+// Inconclusive returns the results which neither succeeded nor were
+// blocked, and so tell nothing about the policies.
+func Inconclusive(results []ProbeResult) []ProbeResult {
+	var inconclusive []ProbeResult
+	for _, r := range results {
+		if !r.Outcome.Conclusive() {
+			inconclusive = append(inconclusive, r)
+		}
+	}
+	return inconclusive
+}
+
+// GetWorkloadEndpoint returns the endpoint of the first pod of the
+// workload.
+func GetWorkloadEndpoint(client *rprojectv3.Client, w *rprojectv3.Workload) (Endpoint, error) {
+	podCollection, err := client.Pod.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"workloadId": w.ID,
+		},
+	})
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("error fetching pods of workload %v: %v", w.Name, err)
+	}
+	if len(podCollection.Data) == 0 {
+		return Endpoint{}, fmt.Errorf("no pods found for workload %v", w.Name)
+	}
+
+	pod := podCollection.Data[0]
+	if len(pod.Containers) == 0 {
+		return Endpoint{}, fmt.Errorf("no containers found in pod %v", pod.Name)
+	}
+
+	e := Endpoint{
+		Project:   pod.ProjectID,
+		Workload:  w.Name,
+		Namespace: pod.NamespaceId,
+		Pod:       pod.Name,
+		Container: pod.Containers[0].Name,
+		Labels:    pod.Labels,
+	}
+	if pod.Status != nil {
+		e.PodIP = pod.Status.PodIp
+	}
+	return e, nil
+}
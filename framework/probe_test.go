@@ -0,0 +1,126 @@
+package framework
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// execFunc is an Executor running a function.
+type execFunc func(namespace, pod, container, command string) (string, error)
+
+func (f execFunc) Exec(namespace, pod, container, command string) (string, error) {
+	return f(namespace, pod, container, command)
+}
+
+func TestProbeWithAgent(t *testing.T) {
+	from := Endpoint{Workload: "w1", Namespace: "ns1", Pod: "w1-abcde", Container: "w1"}
+	to := Endpoint{Workload: "w2", Namespace: "ns2", Pod: "w2-fghij", Container: "w2"}
+
+	var command string
+	outputs := map[string]string{
+		"reachable": `{"protocol":"http","target":"w2.ns2:80","connected":true,"outcome":"success","response":{"protocol":"http","pod":"w2-fghij","clientIP":"10.42.0.5"}}`,
+		"other pod": `{"protocol":"http","target":"w2.ns2:80","connected":true,"outcome":"success","response":{"protocol":"http","pod":"w3-klmno","clientIP":"10.42.0.5"}}`,
+		"timeout":   `{"protocol":"http","target":"w2.ns2:80","connected":false,"outcome":"timeout","error":"Client.Timeout exceeded"}`,
+		"no host":   `{"protocol":"http","target":"w2.ns2:80","connected":false,"outcome":"dns-failure","error":"no such host"}`,
+		"garbage":   "w2-fghij\n",
+		"no agent":  "OCI runtime exec failed: exec: \"probe-agent\": executable file not found in $PATH\n",
+	}
+	want := map[string]bool{"reachable": true}
+	outcomes := map[string]ProbeOutcome{
+		"reachable": Success,
+		"other pod": InfraError,
+		"timeout":   Timeout,
+		"no host":   DNSFailure,
+		"garbage":   InfraError,
+		"no agent":  ExecError,
+	}
+
+	for name, output := range outputs {
+		output := output
+		p := NewProber(execFunc(func(namespace, pod, container, cmd string) (string, error) {
+			command = cmd
+			return output, nil
+		}))
+		p.Agent = true
+
+		result := p.Probe(Pair{From: from, To: to})
+		if result.Reachable != want[name] {
+			t.Errorf("%v: reachable = %v", name, result.Reachable)
+		}
+		if result.Outcome != outcomes[name] {
+			t.Errorf("%v: outcome = %v, want %v", name, result.Outcome, outcomes[name])
+		}
+		if (result.Err != nil) != (name == "garbage") {
+			t.Errorf("%v: err = %v", name, result.Err)
+		}
+		if !strings.HasPrefix(command, "probe-agent probe --protocol http --host w2.ns2 ") {
+			t.Errorf("%v: command = %q", name, command)
+		}
+	}
+}
+
+func TestProbeVia(t *testing.T) {
+	from := Endpoint{Workload: "w1", Namespace: "ns1", Pod: "w1-abcde", Container: "w1"}
+	to := Endpoint{Workload: "w2", Namespace: "ns2", Pod: "w2-fghij", Container: "w2", PodIP: "10.42.0.6"}
+
+	var command string
+	p := NewProber(execFunc(func(namespace, pod, container, cmd string) (string, error) {
+		command = cmd
+		return "w2-fghij", nil
+	}))
+	p.Agent = false
+
+	if result := p.Probe(Pair{From: from, To: to, Via: ViaPodIP}); result.Outcome != Success || command != "curl --max-time 5 -s http://10.42.0.6" {
+		t.Errorf("via pod IP: outcome = %v, command = %q", result.Outcome, command)
+	}
+
+	command = ""
+	result := p.Probe(Pair{From: from, To: to, Via: ViaClusterIP})
+	if result.Outcome != InfraError || result.Err == nil || command != "" {
+		t.Errorf("probed an unresolved ClusterIP: %+v, command = %q", result, command)
+	}
+	if got := result.Pair.String(); got != "ns1/w1 -> ns2/w2 via cluster-ip" {
+		t.Errorf("pair = %q", got)
+	}
+}
+
+func TestProbeAllParallelism(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	p := NewProber(execFunc(func(namespace, pod, container, cmd string) (string, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		// Reply with the pod of the workload of the host, as curl would.
+		fields := strings.Fields(cmd)
+		workload := strings.Split(strings.TrimPrefix(fields[len(fields)-1], "http://"), ".")[0]
+		return "<html>Hello from " + workload + "-abcde</html>", nil
+	}))
+	p.Parallelism = 3
+
+	var pairs []Pair
+	for i := 0; i < 20; i++ {
+		to := Endpoint{Workload: fmt.Sprintf("w%d", i), Namespace: "ns1", Pod: fmt.Sprintf("w%d-abcde", i)}
+		pairs = append(pairs, Pair{From: Endpoint{Workload: "w", Namespace: "ns1", Pod: "w-abcde"}, To: to})
+	}
+	results := p.ProbeAll(pairs)
+
+	if maxRunning > 3 {
+		t.Errorf("ran %d probes at once, expected at most 3", maxRunning)
+	}
+	for i, r := range results {
+		if r.Pair.String() != pairs[i].String() || !r.Reachable {
+			t.Errorf("result %d: %v reachable = %v", i, r.Pair, r.Reachable)
+		}
+	}
+}
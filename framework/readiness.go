@@ -0,0 +1,129 @@
+package framework
+
+import (
+	"fmt"
+	"time"
+
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// readinessPeriod is how often the probe workloads are checked for
+// readiness by the kubelet.
+const readinessPeriod = 2
+
+// NewReadinessProbe returns the readiness probe of the probe workloads,
+// which are ready once they serve HTTP on port 80.
+func NewReadinessProbe() *rprojectv3.Probe {
+	period := int64(readinessPeriod)
+	return &rprojectv3.Probe{
+		Path:          "/",
+		Port:          intstr.FromInt(80),
+		Scheme:        "HTTP",
+		PeriodSeconds: &period,
+	}
+}
+
+// newKubeReadinessProbe is NewReadinessProbe for Kubernetes pods.
+func newKubeReadinessProbe() *corev1.Probe {
+	return &corev1.Probe{
+		Handler: corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   "/",
+				Port:   intstr.FromInt(80),
+				Scheme: corev1.URISchemeHTTP,
+			},
+		},
+		PeriodSeconds: readinessPeriod,
+	}
+}
+
+// WaitForServiceReady waits until the pods selected by the service are
+// all ready, and the endpoints of the service hold the IPs of them all,
+// so that probes sent to the service are not lost to a pod which is
+// starting or is not yet behind the service.
+func (c *KubeClient) WaitForServiceReady(namespace, name string, timeout time.Duration) error {
+	var reason string
+	err := wait.PollImmediate(waitInterval, timeout, func() (bool, error) {
+		reason = c.serviceNotReady(namespace, name)
+		return reason == "", nil
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for service %v/%v to be ready: %v", namespace, name, reason)
+	}
+	return nil
+}
+
+// serviceNotReady returns why the service is not ready, or an empty
+// string if it is.
+func (c *KubeClient) serviceNotReady(namespace, name string) string {
+	service := &corev1.Service{}
+	if err := c.GetObject(ServiceResource, namespace, name, service); err != nil {
+		return err.Error()
+	}
+	if len(service.Spec.Selector) == 0 {
+		return "the service has no selector"
+	}
+
+	pods := &corev1.PodList{}
+	opts := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String()}
+	if err := c.ListObjects(PodResource, namespace, opts, pods); err != nil {
+		return err.Error()
+	}
+	endpoints, err := c.GetEndpoints(namespace, name)
+	if err != nil {
+		return err.Error()
+	}
+	addresses := map[string]bool{}
+	for _, subset := range endpoints.Subsets {
+		for _, a := range subset.Addresses {
+			addresses[a.IP] = true
+		}
+	}
+
+	running := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		running++
+		if !podReady(pod) {
+			return fmt.Sprintf("pod %v is not ready", pod.Name)
+		}
+		if !addresses[pod.Status.PodIP] {
+			return fmt.Sprintf("pod %v (%v) is not in the endpoints", pod.Name, pod.Status.PodIP)
+		}
+	}
+	if running == 0 {
+		return "no pods"
+	}
+	return ""
+}
+
+// WaitForEndpointsReady waits for the service of the workload of every
+// endpoint to be ready.
+func (c *KubeClient) WaitForEndpointsReady(endpoints []Endpoint, timeout time.Duration) error {
+	for _, e := range endpoints {
+		if err := c.WaitForServiceReady(e.Namespace, e.Workload, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func podReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package framework
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestServiceNotReady(t *testing.T) {
+	service := `{"kind":"Service","apiVersion":"v1","metadata":{"name":"w1","namespace":"ns1"},"spec":{"selector":{"app":"w1"}}}`
+	readyPod := `{"metadata":{"name":"w1-abcde"},"status":{"phase":"Running","podIP":"10.42.0.5","conditions":[{"type":"Ready","status":"True"}]}}`
+	startingPod := `{"metadata":{"name":"w1-abcde"},"status":{"phase":"Running","podIP":"10.42.0.5","conditions":[{"type":"Ready","status":"False"}]}}`
+	endpoints := `{"kind":"Endpoints","apiVersion":"v1","metadata":{"name":"w1","namespace":"ns1"},"subsets":[{"addresses":[{"ip":"10.42.0.5"}]}]}`
+	noEndpoints := `{"kind":"Endpoints","apiVersion":"v1","metadata":{"name":"w1","namespace":"ns1"}}`
+
+	tests := []struct {
+		pods      []string
+		endpoints string
+		want      string
+	}{
+		{[]string{readyPod}, endpoints, ""},
+		{[]string{startingPod}, endpoints, "pod w1-abcde is not ready"},
+		{[]string{readyPod}, noEndpoints, "pod w1-abcde (10.42.0.5) is not in the endpoints"},
+		{nil, noEndpoints, "no pods"},
+	}
+	for _, test := range tests {
+		var selector string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/v1/namespaces/ns1/services/w1":
+				w.Write([]byte(service))
+			case "/api/v1/namespaces/ns1/endpoints/w1":
+				w.Write([]byte(test.endpoints))
+			case "/api/v1/namespaces/ns1/pods":
+				selector = r.URL.Query().Get("labelSelector")
+				items := []json.RawMessage{}
+				for _, p := range test.pods {
+					items = append(items, json.RawMessage(p))
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"kind": "PodList", "apiVersion": "v1", "metadata": map[string]interface{}{}, "items": items,
+				})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		c := NewKubeClient(&rest.Config{Host: server.URL})
+		got := c.serviceNotReady("ns1", "w1")
+		server.Close()
+
+		if got != test.want {
+			t.Errorf("serviceNotReady() with pods %v = %q, want %q", test.pods, got, test.want)
+		}
+		if selector != "app=w1" {
+			t.Errorf("pods listed with selector %q", selector)
+		}
+	}
+}
@@ -1,15 +1,33 @@
 package framework
 
 import (
-	"github.com/onsi/ginkgo/reporters"
+	"fmt"
+	"path/filepath"
+
 	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
 	"github.com/onsi/ginkgo/reporters/stenographer"
 )
 
-func NewReporter () reporters.Reporter {
+func NewReporter() reporters.Reporter {
 	config.DefaultReporterConfig.SlowSpecThreshold = 60
 	c := config.DefaultReporterConfig
 	c.SlowSpecThreshold = 60
-	s:= stenographer.New(!config.DefaultReporterConfig.NoColor, config.GinkgoConfig.FlakeAttempts > 1)
+	s := stenographer.New(!config.DefaultReporterConfig.NoColor, config.GinkgoConfig.FlakeAttempts > 1)
 	return reporters.NewDefaultReporter(c, s)
 }
+
+// NewFileReporters returns the reporters writing a JUnit XML, a JSON
+// and an HTML report of the suite into reportDir. Running in parallel,
+// every node writes its own files, with the results of its own specs.
+func NewFileReporters(reportDir string, connectivity *ConnectivityRecorder, convergence *ConvergenceRecorder, scale *ScaleRecorder) []reporters.Reporter {
+	suffix := ""
+	if config.GinkgoConfig.ParallelTotal > 1 {
+		suffix = fmt.Sprintf("_%d", config.GinkgoConfig.ParallelNode)
+	}
+	return []reporters.Reporter{
+		reporters.NewJUnitReporter(filepath.Join(reportDir, "junit"+suffix+".xml")),
+		NewJSONReporter(filepath.Join(reportDir, "report"+suffix+".json"), connectivity, convergence, scale),
+		NewHTMLReporter(filepath.Join(reportDir, "report"+suffix+".html"), connectivity, convergence, scale),
+	}
+}
@@ -0,0 +1,257 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	normanclientbase "github.com/rancher/norman/clientbase"
+	normantypes "github.com/rancher/norman/types"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultBackoff is the backoff of the retries of Rancher API calls
+// when RANCHER_API_RETRIES and RANCHER_API_RETRY_INTERVAL are not set.
+// Steps is the number of attempts, including the first one.
+var DefaultBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+// BackoffFromEnvVars returns DefaultBackoff with the number of attempts
+// in RANCHER_API_RETRIES and the initial interval between them in
+// RANCHER_API_RETRY_INTERVAL, when set.
+func BackoffFromEnvVars() (wait.Backoff, error) {
+	backoff := DefaultBackoff
+	if v := os.Getenv("RANCHER_API_RETRIES"); v != "" {
+		steps, err := strconv.Atoi(v)
+		if err != nil || steps < 1 {
+			return backoff, fmt.Errorf("RANCHER_API_RETRIES must be a positive number, got %q", v)
+		}
+		backoff.Steps = steps
+	}
+	if v := os.Getenv("RANCHER_API_RETRY_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return backoff, fmt.Errorf("RANCHER_API_RETRY_INTERVAL must be a positive duration, got %q", v)
+		}
+		backoff.Duration = interval
+	}
+	return backoff, nil
+}
+
+// RetryTransport retries the requests which fail with a transient
+// error with exponential backoff:
+//
+//   - 5xx responses to GET, HEAD, PUT and DELETE requests;
+//   - 409 responses to creations, unless the resource already exists;
+//   - connection errors of GET and HEAD requests.
+//
+// Creations failing with a 5xx error are not retried, since Rancher may
+// have created the resource before failing; see RetryCreate. Conflicts
+// of updates and actions are not retried either, since they have to be
+// made again against the current resource; see RetryOnConflict.
+type RetryTransport struct {
+	Transport http.RoundTripper
+	Backoff   wait.Backoff
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.Transport.RoundTrip(req)
+		reason := retryReason(req, resp, err)
+		if reason == "" || attempt >= backoff.Steps {
+			return resp, err
+		}
+
+		next, rewindErr := rewindRequest(req)
+		if rewindErr != nil {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := backoff.Duration
+		if backoff.Jitter > 0 {
+			delay = wait.Jitter(delay, backoff.Jitter)
+		}
+		logrus.Warnf("retrying %v %v in %v after %v (attempt %v of %v)", req.Method, req.URL, delay, reason, attempt, backoff.Steps)
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		backoff.Duration = time.Duration(float64(backoff.Duration) * backoff.Factor)
+		req = next
+	}
+}
+
+// retryReason returns why the request should be retried, or an empty
+// string if it should not.
+func retryReason(req *http.Request, resp *http.Response, err error) string {
+	if err != nil {
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			return err.Error()
+		}
+		return ""
+	}
+	switch {
+	case resp.StatusCode >= 500 && idempotent(req):
+		return resp.Status
+	case resp.StatusCode == http.StatusConflict && creation(req):
+		if alreadyExists(resp) {
+			return ""
+		}
+		return resp.Status
+	}
+	return ""
+}
+
+// idempotent returns whether sending the request again has no other
+// effect than sending it once.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// creation returns whether the request creates a resource in a
+// collection, as opposed to running an action on a resource.
+func creation(req *http.Request) bool {
+	return req.Method == http.MethodPost && req.URL.Query().Get("action") == ""
+}
+
+// alreadyExists returns whether the body of the response is an error
+// of the Rancher API with the AlreadyExists code, or a Kubernetes
+// Status with the AlreadyExists reason, leaving the body readable.
+func alreadyExists(resp *http.Response) bool {
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	// The code of a Status is its HTTP status code, a number.
+	apiError := struct {
+		Code   interface{} `json:"code"`
+		Reason string      `json:"reason"`
+	}{}
+	json.Unmarshal(data, &apiError)
+	return apiError.Code == "AlreadyExists" || apiError.Reason == "AlreadyExists"
+}
+
+// rewindRequest returns a copy of the request with a fresh body, to
+// send it again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	next := req.WithContext(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return next, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be rewound")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next.Body = body
+	return next, nil
+}
+
+// IsServerError returns whether the error is a 5xx error of the
+// Rancher API.
+func IsServerError(err error) bool {
+	apiError, ok := err.(*normanclientbase.APIError)
+	return ok && apiError.StatusCode >= 500
+}
+
+// IsConflict returns whether the error is a 409 error of the Rancher API.
+func IsConflict(err error) bool {
+	apiError, ok := err.(*normanclientbase.APIError)
+	return ok && apiError.StatusCode == http.StatusConflict
+}
+
+// RetryOnConflict runs update until it does not fail with a conflict,
+// with exponential backoff. update must fetch the current resource
+// before updating it.
+func RetryOnConflict(backoff wait.Backoff, update func() error) error {
+	var err error
+	waitErr := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		err = update()
+		if IsConflict(err) {
+			logrus.Warnf("retrying after conflict: %v", err)
+			return false, nil
+		}
+		return true, err
+	})
+	if waitErr == wait.ErrWaitTimeout {
+		return err
+	}
+	return waitErr
+}
+
+// RetryCreate runs create until it does not fail with a 5xx error, with
+// exponential backoff. Since Rancher may have created the resource
+// before failing, lookup is run before each retry, and stops them when
+// it finds the resource.
+func RetryCreate(backoff wait.Backoff, create func() error, lookup func() (bool, error)) error {
+	var err error
+	attempt := 0
+	waitErr := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		if attempt++; attempt > 1 {
+			found, lookupErr := lookup()
+			if lookupErr != nil {
+				logrus.Warnf("error looking up the resource before creating it again: %v", lookupErr)
+			} else if found {
+				err = nil
+				return true, nil
+			}
+		}
+		err = create()
+		if IsServerError(err) {
+			logrus.Warnf("retrying creation after server error: %v", err)
+			return false, nil
+		}
+		return true, err
+	})
+	if waitErr == wait.ErrWaitTimeout {
+		return err
+	}
+	return waitErr
+}
+
+// UpdateResource updates the resource of the schema type with the ID
+// with the updates computed by update from its current state, fetching
+// it again and computing them again when the update conflicts with
+// another change.
+func UpdateResource(backoff wait.Backoff, client *normanclientbase.APIBaseClient, schemaType, id string, update func(current map[string]interface{}) interface{}, respObject interface{}) error {
+	return RetryOnConflict(backoff, func() error {
+		current := map[string]interface{}{}
+		if err := client.ByID(schemaType, id, &current); err != nil {
+			return err
+		}
+		resource := &normantypes.Resource{ID: id, Type: schemaType, Links: map[string]string{}}
+		if links, ok := current["links"].(map[string]interface{}); ok {
+			if self, ok := links["self"].(string); ok {
+				resource.Links["self"] = self
+			}
+		}
+		return client.Update(schemaType, resource, update(current), respObject)
+	})
+}
@@ -0,0 +1,228 @@
+package framework
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	normanclientbase "github.com/rancher/norman/clientbase"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var testBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 3}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		method   string
+		query    string
+		statuses []int
+		body     string
+		want     int
+		attempts int
+	}{
+		{"GET", "", []int{503, 502, 200}, "", 200, 3},
+		{"PUT", "", []int{502, 200}, "", 200, 2},
+		{"DELETE", "", []int{504, 204}, "", 204, 2},
+		{"POST", "", []int{500, 201}, "", 500, 1},
+		{"POST", "", []int{409, 201}, `{"code":"Conflict"}`, 201, 2},
+		{"POST", "", []int{409, 201}, `{"code":"AlreadyExists"}`, 409, 1},
+		{"POST", "", []int{409, 201}, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"AlreadyExists","code":409}`, 409, 1},
+		{"POST", "", []int{409, 201}, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","code":409}`, 201, 2},
+		{"POST", "?action=move", []int{409, 200}, `{"code":"Conflict"}`, 409, 1},
+		{"POST", "?action=move", []int{503, 200}, "", 503, 1},
+		{"PUT", "", []int{409, 200}, `{"code":"Conflict"}`, 409, 1},
+		{"GET", "", []int{404, 200}, "", 404, 1},
+		{"GET", "", []int{503, 503, 503, 200}, "", 503, 3},
+	}
+	for _, test := range tests {
+		attempts := 0
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(data))
+			status := test.statuses[attempts]
+			attempts++
+			w.WriteHeader(status)
+			if status == http.StatusConflict {
+				w.Write([]byte(test.body))
+			}
+		}))
+
+		client := &http.Client{Transport: &RetryTransport{Transport: http.DefaultTransport, Backoff: testBackoff}}
+		req, _ := http.NewRequest(test.method, server.URL+test.query, strings.NewReader("payload"))
+		resp, err := client.Do(req)
+		server.Close()
+		if err != nil {
+			t.Errorf("%v%v %v: err: %v", test.method, test.query, test.statuses, err)
+			continue
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.want || attempts != test.attempts {
+			t.Errorf("%v%v %v: got %v after %v attempts, want %v after %v", test.method, test.query, test.statuses, resp.StatusCode, attempts, test.want, test.attempts)
+		}
+		if resp.StatusCode == http.StatusConflict && string(data) != test.body {
+			t.Errorf("%v %v: body = %q, want %q", test.method, test.statuses, data, test.body)
+		}
+		for _, b := range bodies {
+			if b != "payload" {
+				t.Errorf("%v %v: request bodies = %q", test.method, test.statuses, bodies)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	attempts := 0
+	err := RetryOnConflict(testBackoff, func() error {
+		if attempts++; attempts < 3 {
+			return &normanclientbase.APIError{StatusCode: http.StatusConflict}
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("got %v after %v attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = RetryOnConflict(testBackoff, func() error {
+		attempts++
+		return &normanclientbase.APIError{StatusCode: http.StatusConflict}
+	})
+	if !IsConflict(err) || attempts != testBackoff.Steps {
+		t.Errorf("got %v after %v attempts", err, attempts)
+	}
+
+	attempts = 0
+	err = RetryOnConflict(testBackoff, func() error {
+		attempts++
+		return &normanclientbase.APIError{StatusCode: http.StatusNotFound}
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("got %v after %v attempts", err, attempts)
+	}
+}
+
+func TestRetryCreate(t *testing.T) {
+	serverError := &normanclientbase.APIError{StatusCode: http.StatusBadGateway}
+
+	// The first creation fails, but the resource was created.
+	creates, lookups := 0, 0
+	err := RetryCreate(testBackoff, func() error {
+		creates++
+		return serverError
+	}, func() (bool, error) {
+		lookups++
+		return true, nil
+	})
+	if err != nil || creates != 1 || lookups != 1 {
+		t.Errorf("got %v after %v creations and %v lookups", err, creates, lookups)
+	}
+
+	// The first creation fails before creating the resource.
+	creates, lookups = 0, 0
+	err = RetryCreate(testBackoff, func() error {
+		if creates++; creates < 2 {
+			return serverError
+		}
+		return nil
+	}, func() (bool, error) {
+		lookups++
+		return false, nil
+	})
+	if err != nil || creates != 2 || lookups != 1 {
+		t.Errorf("got %v after %v creations and %v lookups", err, creates, lookups)
+	}
+
+	// Other errors are not retried.
+	creates = 0
+	err = RetryCreate(testBackoff, func() error {
+		creates++
+		return &normanclientbase.APIError{StatusCode: http.StatusUnprocessableEntity}
+	}, func() (bool, error) {
+		t.Errorf("unexpected lookup")
+		return false, nil
+	})
+	if err == nil || creates != 1 {
+		t.Errorf("got %v after %v creations", err, creates)
+	}
+}
+
+func TestUpdateResource(t *testing.T) {
+	var server *httptest.Server
+	version, puts := 1, 0
+	var updates []map[string]interface{}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v3":
+			w.Header().Set("X-API-Schemas", server.URL+"/v3")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": []map[string]interface{}{{
+					"id":              "namespace",
+					"resourceMethods": []string{"GET", "PUT"},
+					"links":           map[string]string{"collection": server.URL + "/v3/namespaces"},
+				}},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/v3/namespaces/ns1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":      "ns1",
+				"version": version,
+				"links":   map[string]string{"self": server.URL + "/v3/namespaces/ns1"},
+			})
+		case r.Method == http.MethodPut && r.URL.Path == "/v3/namespaces/ns1":
+			update := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&update)
+			updates = append(updates, update)
+			// Another change is made before the first update.
+			if puts++; puts == 1 {
+				version++
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]string{"type": "error", "code": "Conflict"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "ns1"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := normanclientbase.NewAPIClient(&normanclientbase.ClientOpts{URL: server.URL + "/v3"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	err = UpdateResource(testBackoff, &client, "namespace", "ns1", func(current map[string]interface{}) interface{} {
+		return map[string]interface{}{"version": current["version"]}
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(updates) != 2 || updates[0]["version"] != float64(1) || updates[1]["version"] != float64(2) {
+		t.Errorf("expected the update to be made again against the current resource, got %v", updates)
+	}
+}
+
+func TestBackoffFromEnvVars(t *testing.T) {
+	defer os.Unsetenv("RANCHER_API_RETRIES")
+	defer os.Unsetenv("RANCHER_API_RETRY_INTERVAL")
+
+	os.Setenv("RANCHER_API_RETRIES", "3")
+	os.Setenv("RANCHER_API_RETRY_INTERVAL", "2s")
+	backoff, err := BackoffFromEnvVars()
+	if err != nil || backoff.Steps != 3 || backoff.Duration != 2*time.Second || backoff.Factor != DefaultBackoff.Factor {
+		t.Errorf("BackoffFromEnvVars() = %+v, %v", backoff, err)
+	}
+
+	os.Setenv("RANCHER_API_RETRIES", "0")
+	if _, err := BackoffFromEnvVars(); err == nil {
+		t.Errorf("BackoffFromEnvVars() accepted 0 retries")
+	}
+}
@@ -0,0 +1,194 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sampling of the pairs probed by the scale specs: enough pairs to
+// estimate the proportion of pairs in their expected state within
+// SampleMargin, with a confidence of 95%.
+const (
+	SampleMargin = 0.05
+	sampleZ      = 1.96
+)
+
+// ScaleSpec is the size of the topology of a scale spec: Projects
+// projects of Namespaces namespaces of Workloads workloads each.
+type ScaleSpec struct {
+	Projects   int
+	Namespaces int
+	Workloads  int
+}
+
+// ParseScaleSpec parses a spec written as <projects>x<namespaces>x<workloads>.
+func ParseScaleSpec(v string) (ScaleSpec, error) {
+	s := ScaleSpec{}
+	_, err := fmt.Sscanf(v, "%dx%dx%d", &s.Projects, &s.Namespaces, &s.Workloads)
+	if err != nil || s.String() != v {
+		return ScaleSpec{}, fmt.Errorf("invalid scale %q, expected <projects>x<namespaces>x<workloads>", v)
+	}
+	if s.Projects < 1 || s.Namespaces < 1 || s.Workloads < 1 {
+		return ScaleSpec{}, fmt.Errorf("invalid scale %q, every count must be at least 1", v)
+	}
+	return s, nil
+}
+
+// ScaleSpecsFromEnvVars returns the specs listed, separated by commas,
+// in RANCHER_TEST_SCALE. There are none when it is not set.
+func ScaleSpecsFromEnvVars() ([]ScaleSpec, error) {
+	v := os.Getenv("RANCHER_TEST_SCALE")
+	if v == "" {
+		return nil, nil
+	}
+	var specs []ScaleSpec
+	for _, part := range strings.Split(v, ",") {
+		s, err := ParseScaleSpec(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RANCHER_TEST_SCALE: %v", err)
+		}
+		specs = append(specs, s)
+	}
+	return specs, nil
+}
+
+func (s ScaleSpec) String() string {
+	return fmt.Sprintf("%dx%dx%d", s.Projects, s.Namespaces, s.Workloads)
+}
+
+// Topology returns the spec of the topology, with the suffix appended
+// to the names of its projects and namespaces.
+func (s ScaleSpec) Topology(suffix string) TopologySpec {
+	spec := TopologySpec{}
+	for p := 1; p <= s.Projects; p++ {
+		project := fmt.Sprintf("scale-p%d%v", p, suffix)
+		spec[project] = map[string][]string{}
+		for n := 1; n <= s.Namespaces; n++ {
+			var workloads []string
+			for w := 1; w <= s.Workloads; w++ {
+				workloads = append(workloads, fmt.Sprintf("w%d", w))
+			}
+			spec[project][fmt.Sprintf("scale-p%d-ns%d%v", p, n, suffix)] = workloads
+		}
+	}
+	return spec
+}
+
+// SampleSize returns the number of items to sample out of the
+// population to estimate a proportion within SampleMargin.
+func SampleSize(population int) int {
+	if population == 0 {
+		return 0
+	}
+	n0 := sampleZ * sampleZ * 0.25 / (SampleMargin * SampleMargin)
+	n := int(math.Ceil(n0 / (1 + (n0-1)/float64(population))))
+	if n > population {
+		return population
+	}
+	return n
+}
+
+// SamplePairs returns a sample of the ordered pairs of distinct
+// endpoints, stratified by whether the endpoints are in the same
+// project, so that both isolated and allowed pairs are sampled
+// enough.
+func SamplePairs(endpoints []Endpoint, rng *rand.Rand) []Pair {
+	return samplePairs(endpoints, rng, func(from, to Endpoint) bool { return true })
+}
+
+// SamplePairsWith is SamplePairs for the pairs from or to the
+// endpoints of the namespace only.
+func SamplePairsWith(endpoints []Endpoint, namespace string, rng *rand.Rand) []Pair {
+	return samplePairs(endpoints, rng, func(from, to Endpoint) bool {
+		return from.Namespace == namespace || to.Namespace == namespace
+	})
+}
+
+func samplePairs(endpoints []Endpoint, rng *rand.Rand, include func(from, to Endpoint) bool) []Pair {
+	var same, cross []Pair
+	for _, from := range endpoints {
+		for _, to := range endpoints {
+			if from.String() == to.String() || !include(from, to) {
+				continue
+			}
+			if from.Project == to.Project {
+				same = append(same, Pair{From: from, To: to})
+			} else {
+				cross = append(cross, Pair{From: from, To: to})
+			}
+		}
+	}
+	return append(sample(same, rng), sample(cross, rng)...)
+}
+
+func sample(pairs []Pair, rng *rand.Rand) []Pair {
+	n := SampleSize(len(pairs))
+	var sampled []Pair
+	for _, i := range rng.Perm(len(pairs))[:n] {
+		sampled = append(sampled, pairs[i])
+	}
+	return sampled
+}
+
+// ScaleResult is what a scale spec measured.
+type ScaleResult struct {
+	Spec      ScaleSpec
+	Endpoints int
+	Pairs     int
+	Sampled   int
+	Creation  time.Duration
+	Policies  int
+
+	// Convergence summarizes the samples of the pairs involving a
+	// namespace moved to another project.
+	Convergence []ConvergenceSummary
+}
+
+// ScaleRecorder collects the results of the scale specs. It is safe for
+// concurrent use.
+type ScaleRecorder struct {
+	mu      sync.Mutex
+	results []ScaleResult
+}
+
+// Record adds the result.
+func (r *ScaleRecorder) Record(result ScaleResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+// Results returns a copy of the recorded results.
+func (r *ScaleRecorder) Results() []ScaleResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ScaleResult(nil), r.results...)
+}
+
+// WriteSummary writes the results in a human readable form, one line
+// per spec and kind of convergence.
+func (r *ScaleRecorder) WriteSummary(w io.Writer) {
+	results := r.Results()
+	if len(results) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%-12s %9s %7s %7s %10s %8s %-16s %10s %10s %10s\n",
+		"SCALE", "ENDPOINTS", "PAIRS", "SAMPLED", "CREATION", "POLICIES", "KIND", "P50", "P99", "MAX")
+	for _, result := range results {
+		prefix := fmt.Sprintf("%-12v %9d %7d %7d %10v %8d", result.Spec, result.Endpoints, result.Pairs,
+			result.Sampled, result.Creation.Round(time.Second), result.Policies)
+		if len(result.Convergence) == 0 {
+			fmt.Fprintln(w, prefix)
+		}
+		for _, s := range result.Convergence {
+			fmt.Fprintf(w, "%v %-16s %10v %10v %10v\n", prefix, s.Kind,
+				s.P50.Round(time.Millisecond), s.P99.Round(time.Millisecond), s.Max.Round(time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package framework
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScaleSpec(t *testing.T) {
+	s, err := ParseScaleSpec("20x5x2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (ScaleSpec{Projects: 20, Namespaces: 5, Workloads: 2}) {
+		t.Errorf("got %+v", s)
+	}
+	for _, v := range []string{"", "20x5", "20x5x2x1", "20x0x2", "ax5x2", "20x5x2 "} {
+		if _, err := ParseScaleSpec(v); err == nil {
+			t.Errorf("%q: expected an error", v)
+		}
+	}
+}
+
+func TestScaleSpecsFromEnvVars(t *testing.T) {
+	defer os.Unsetenv("RANCHER_TEST_SCALE")
+
+	os.Unsetenv("RANCHER_TEST_SCALE")
+	if specs, err := ScaleSpecsFromEnvVars(); err != nil || specs != nil {
+		t.Errorf("unset: got %v, %v", specs, err)
+	}
+
+	os.Setenv("RANCHER_TEST_SCALE", "2x1x1, 10x5x2")
+	specs, err := ScaleSpecsFromEnvVars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(specs) != "[2x1x1 10x5x2]" {
+		t.Errorf("got %v", specs)
+	}
+
+	os.Setenv("RANCHER_TEST_SCALE", "2x1x1,big")
+	if _, err := ScaleSpecsFromEnvVars(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestScaleSpecTopology(t *testing.T) {
+	spec := ScaleSpec{Projects: 3, Namespaces: 2, Workloads: 4}.Topology("-n1")
+	if len(spec) != 3 {
+		t.Fatalf("got %d projects", len(spec))
+	}
+	namespaces := spec.NamespaceNames("scale-p2-n1")
+	if fmt.Sprint(namespaces) != "[scale-p2-ns1-n1 scale-p2-ns2-n1]" {
+		t.Errorf("got namespaces %v", namespaces)
+	}
+	if workloads := spec["scale-p2-n1"]["scale-p2-ns2-n1"]; fmt.Sprint(workloads) != "[w1 w2 w3 w4]" {
+		t.Errorf("got workloads %v", workloads)
+	}
+}
+
+func TestSampleSize(t *testing.T) {
+	tests := []struct {
+		population int
+		want       int
+	}{
+		{0, 0},
+		{1, 1},
+		{10, 10},
+		{1000, 278},
+		{1000000, 385},
+	}
+	for _, test := range tests {
+		if got := SampleSize(test.population); got != test.want {
+			t.Errorf("SampleSize(%d) = %d, want %d", test.population, got, test.want)
+		}
+	}
+}
+
+func scaleEndpoints(projects, workloads int) []Endpoint {
+	var endpoints []Endpoint
+	for p := 1; p <= projects; p++ {
+		for w := 1; w <= workloads; w++ {
+			endpoints = append(endpoints, Endpoint{
+				Project:   fmt.Sprintf("p-%d", p),
+				Namespace: fmt.Sprintf("ns%d", p),
+				Workload:  fmt.Sprintf("w%d", w),
+			})
+		}
+	}
+	return endpoints
+}
+
+func TestSamplePairs(t *testing.T) {
+	// 20 projects of 10 endpoints: 1800 pairs within a project and
+	// 38000 across projects.
+	pairs := SamplePairs(scaleEndpoints(20, 10), rand.New(rand.NewSource(1)))
+
+	same, cross := 0, 0
+	seen := map[string]bool{}
+	for _, p := range pairs {
+		if p.From.String() == p.To.String() {
+			t.Errorf("%v: same endpoint", p)
+		}
+		if seen[p.String()] {
+			t.Errorf("%v: sampled twice", p)
+		}
+		seen[p.String()] = true
+		if p.From.Project == p.To.Project {
+			same++
+		} else {
+			cross++
+		}
+	}
+	if same != SampleSize(1800) || cross != SampleSize(38000) {
+		t.Errorf("sampled %d pairs within projects and %d across", same, cross)
+	}
+}
+
+func TestSamplePairsWith(t *testing.T) {
+	pairs := SamplePairsWith(scaleEndpoints(3, 2), "ns2", rand.New(rand.NewSource(1)))
+	// 2 pairs within ns2, and 16 from or to ns2 across projects.
+	if len(pairs) != 18 {
+		t.Errorf("got %d pairs", len(pairs))
+	}
+	for _, p := range pairs {
+		if p.From.Namespace != "ns2" && p.To.Namespace != "ns2" {
+			t.Errorf("%v: does not involve ns2", p)
+		}
+	}
+}
+
+func TestScaleRecorderWriteSummary(t *testing.T) {
+	r := &ScaleRecorder{}
+	buf := &bytes.Buffer{}
+	r.WriteSummary(buf)
+	if buf.Len() != 0 {
+		t.Errorf("expected no summary without results, got %q", buf.String())
+	}
+
+	r.Record(ScaleResult{
+		Spec:      ScaleSpec{Projects: 2, Namespaces: 1, Workloads: 1},
+		Endpoints: 2,
+		Pairs:     2,
+		Sampled:   2,
+		Creation:  90 * time.Second,
+		Policies:  4,
+		Convergence: []ConvergenceSummary{
+			{Kind: TimeToEnforce, Count: 1, P50: time.Second, P99: time.Second, Max: time.Second},
+			{Kind: TimeToRelease, Count: 1, P50: 2 * time.Second, P99: 2 * time.Second, Max: 2 * time.Second},
+		},
+	})
+	r.Record(ScaleResult{Spec: ScaleSpec{Projects: 1, Namespaces: 1, Workloads: 2}, Endpoints: 2})
+	r.WriteSummary(buf)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 lines, got:\n%v", buf.String())
+	}
+	if !strings.HasPrefix(lines[1], "2x1x1") || !strings.Contains(lines[1], "1m30s") || !strings.Contains(lines[1], string(TimeToEnforce)) {
+		t.Errorf("unexpected line %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "1x1x2") {
+		t.Errorf("unexpected line %q", lines[3])
+	}
+}
@@ -11,69 +11,182 @@ import (
 	rclusterv3 "github.com/rancher/types/client/cluster/v3"
 	rmgmtv3 "github.com/rancher/types/client/management/v3"
 	rprojectv3 "github.com/rancher/types/client/project/v3"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 var (
-	insecureClient = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+	// insecureTransport sends the Rancher API calls of every client,
+	// without verifying the certificate of the server.
+	insecureTransport = &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
 		},
 	}
+
+	// insecureClient sends the calls made before a RancherServer and
+	// its backoff exist, such as the logins.
+	insecureClient = newAPIClient(DefaultBackoff)
 )
 
+// newAPIClient returns a client of the Rancher API retrying the calls
+// with the backoff.
+func newAPIClient(backoff wait.Backoff) *http.Client {
+	return &http.Client{
+		Transport: &RetryTransport{Transport: insecureTransport, Backoff: backoff},
+	}
+}
+
 // RancherServer is used to hold the information to a
 // single Rancher Server installation. This is pointing to
 // the IP address of the install, which means it's agnostic to
-// HA installation.
+// HA installation. Once created, it is safe for concurrent use.
 type RancherServer struct {
+	Credentials
+
 	URL                  string
-	AccessKey            string
-	SecretKey            string
-	TokenKey             string
 	ClusterName          string
 	APIEndPoint          string
 	ManagementClient     *rmgmtv3.Client
 	Cluster              map[string]*rclusterv3.Client
 	DefaultClusterClient *rclusterv3.Client
 	DefaultCluster       *rmgmtv3.Cluster
+
+	// KubeClient accesses the Kubernetes API of the default cluster
+	// through the Rancher server.
+	*KubeClient
+
+	// Transcripts records the commands run by Exec when set.
+	Transcripts *TranscriptRecorder
+
+	// Backoff is the backoff of the retries of the API calls of the
+	// clients. It is not changed once the server is created.
+	Backoff wait.Backoff
+
+	// apiClient sends the Rancher API calls of the norman clients.
+	apiClient *http.Client
+
+	// MintedToken is the token minted for the run after logging in
+	// with a username and password, and MintedClusterToken the one
+	// scoped to the default cluster, which Close deletes.
+	MintedToken        *rmgmtv3.Token
+	MintedClusterToken *rmgmtv3.Token
+}
+
+// ServerConfig is what it takes to connect to a Rancher server. It is
+// marshalled to share a connection, and the token minted for it, with
+// the other nodes of a parallel run.
+type ServerConfig struct {
+	URL         string       `json:"url"`
+	ClusterName string       `json:"clusterName,omitempty"`
+	Credentials Credentials  `json:"credentials"`
+	Backoff     wait.Backoff `json:"backoff"`
+}
+
+// ServerConfigFromEnvVars returns the ServerConfig in the environment
+// variables.
+func ServerConfigFromEnvVars() (ServerConfig, error) {
+	config := ServerConfig{
+		URL:         os.Getenv("RANCHER_SERVER_URL"),
+		ClusterName: os.Getenv("RANCHER_DEFAULT_CLUSTER_NAME"),
+	}
+	if config.URL == "" {
+		return config, fmt.Errorf("RANCHER_SERVER_URL not specified")
+	}
+
+	var err error
+	if config.Credentials, err = CredentialsFromEnvVars(); err != nil {
+		return config, err
+	}
+	if config.Backoff, err = BackoffFromEnvVars(); err != nil {
+		return config, err
+	}
+	return config, nil
 }
 
 // NewRancherServerFromEnvVars creates a RancherServer struct
 // by reading the information from environment variables
 func NewRancherServerFromEnvVars() (*RancherServer, error) {
-	var err error
-	var url, accessKey, secretKey, tokenKey, clusterName string
-	var apiEndpoint string
-	var mgmtClient *rmgmtv3.Client
-	var clusterClient *rclusterv3.Client
-	var rs *RancherServer
+	config, err := ServerConfigFromEnvVars()
+	if err != nil {
+		return nil, err
+	}
+	return NewRancherServerFromConfig(config)
+}
+
+// NewRancherServerFromConfig creates a RancherServer from the config,
+// such as the one of another RancherServer. Only the RancherServer
+// which minted its token deletes it.
+func NewRancherServerFromConfig(config ServerConfig) (*RancherServer, error) {
+	backoff := config.Backoff
+	if backoff.Steps <= 0 {
+		backoff = DefaultBackoff
+	}
+	return newRancherServer(config.URL, config.ClusterName, config.Credentials, backoff)
+}
 
-	if url = os.Getenv("RANCHER_SERVER_URL"); url == "" {
-		return nil, fmt.Errorf("RANCHER_SERVER_URL not specified")
+// Config returns the config connecting to the same server and default
+// cluster with the same token, without the password it was minted with.
+func (rs *RancherServer) Config() ServerConfig {
+	credentials := rs.Credentials
+	if credentials.HasToken() {
+		credentials.Username, credentials.Password = "", ""
+	}
+	return ServerConfig{
+		URL:         rs.URL,
+		ClusterName: rs.DefaultCluster.Name,
+		Credentials: credentials,
+		Backoff:     rs.Backoff,
 	}
-	apiEndpoint = url + "/v3"
+}
 
-	accessKey = os.Getenv("RANCHER_ACCESS_KEY")
-	secretKey = os.Getenv("RANCHER_SECRET_KEY")
-	tokenKey = os.Getenv("RANCHER_TOKEN")
+// newRancherServer creates a RancherServer for the server at url,
+// whose default cluster is named clusterName, or is the only cluster
+// when clusterName is empty, whose API calls are retried with the
+// backoff. Credentials without a token log in first, and the token of
+// the login is deleted if connecting fails after it.
+func newRancherServer(url, clusterName string, credentials Credentials, backoff wait.Backoff) (*RancherServer, error) {
+	if credentials.HasToken() {
+		return connectRancherServer(url, clusterName, credentials, backoff, false)
+	}
 
-	if accessKey == "" && secretKey == "" && tokenKey == "" {
-		return rs, fmt.Errorf("either access/secret key or token needs to be specified")
+	if err := credentials.Login(url); err != nil {
+		return nil, err
 	}
-	clusterName = os.Getenv("RANCHER_DEFAULT_CLUSTER_NAME")
+	rs, err := connectRancherServer(url, clusterName, credentials, backoff, true)
+	if err != nil {
+		// rs holds the session token, or the token minted in its place.
+		if deleteErr := rs.DeleteToken(url); deleteErr != nil {
+			logrus.Warnf("%v", deleteErr)
+		}
+		return nil, err
+	}
+	return rs, nil
+}
 
-	mgmtClientOpts := normanclientbase.ClientOpts{
-		URL:        apiEndpoint,
-		AccessKey:  accessKey,
-		SecretKey:  secretKey,
-		TokenKey:   tokenKey,
-		HTTPClient: insecureClient,
+// connectRancherServer creates the clients of a RancherServer with the
+// credentials. The session token of a login is exchanged for a minted
+// one. On failure, the returned server, if any, holds the credentials
+// with the token to delete.
+func connectRancherServer(url, clusterName string, credentials Credentials, backoff wait.Backoff, loggedIn bool) (*RancherServer, error) {
+	apiEndpoint := url + "/v3"
+	failed := func(err error) (*RancherServer, error) {
+		return &RancherServer{Credentials: credentials}, err
+	}
+	apiClient := newAPIClient(backoff)
+	clientOpts := func(url string) *normanclientbase.ClientOpts {
+		opts := credentials.ClientOpts(url)
+		opts.HTTPClient = apiClient
+		return opts
 	}
-	mgmtClient, err = rmgmtv3.NewClient(&mgmtClientOpts)
+
+	mgmtClient, err := rmgmtv3.NewClient(clientOpts(apiEndpoint))
 	if err != nil {
-		return rs, fmt.Errorf("error creating managment client: %v", err)
+		return failed(fmt.Errorf("error creating managment client: %v", err))
+	}
+
+	if err := credentials.lookupExpiry(mgmtClient); err != nil {
+		return failed(err)
 	}
 
 	clusterListOpts := &normantypes.ListOpts{}
@@ -83,53 +196,125 @@ func NewRancherServerFromEnvVars() (*RancherServer, error) {
 
 	clusterCollection, err := mgmtClient.Cluster.List(clusterListOpts)
 	if err != nil {
-		return rs, fmt.Errorf("error fetching cluster list: %v", err)
+		return failed(fmt.Errorf("error fetching cluster list: %v", err))
 	}
 	if clusterName == "" && len(clusterCollection.Data) != 1 {
-		return rs, fmt.Errorf("found %v clusters, expected either to find one cluster or default cluster name to be specified", len(clusterCollection.Data))
+		return failed(fmt.Errorf("found %v clusters, expected either to find one cluster or default cluster name to be specified", len(clusterCollection.Data)))
+	}
+	if len(clusterCollection.Data) == 0 {
+		return failed(fmt.Errorf("cluster %v not found", clusterName))
 	}
 
 	defaultCluster := clusterCollection.Data[0]
 
-	clusterClientOpts := normanclientbase.ClientOpts{
-		URL:        defaultCluster.Links["self"],
-		AccessKey:  accessKey,
-		SecretKey:  secretKey,
-		TokenKey:   tokenKey,
-		HTTPClient: insecureClient,
+	// The session token of the login is exchanged for tokens which
+	// expire shortly after the run: one for /v3, and one scoped to the
+	// default cluster for its Kubernetes API.
+	var mintedToken, mintedClusterToken *rmgmtv3.Token
+	if loggedIn {
+		ttl, err := TokenTTLFromEnvVars()
+		if err != nil {
+			return failed(err)
+		}
+		mintedToken, err = credentials.MintToken(mgmtClient, ttl)
+		if err != nil {
+			return failed(err)
+		}
+		mintedClusterToken, err = credentials.MintClusterToken(mgmtClient, defaultCluster.ID, ttl)
+		if err != nil {
+			return failed(err)
+		}
+		mgmtClient, err = rmgmtv3.NewClient(clientOpts(apiEndpoint))
+		if err != nil {
+			return failed(fmt.Errorf("error creating managment client: %v", err))
+		}
 	}
-	clusterClient, err = rclusterv3.NewClient(&clusterClientOpts)
+
+	clusterClient, err := rclusterv3.NewClient(clientOpts(defaultCluster.Links["self"]))
 	if err != nil {
-		return rs, fmt.Errorf("error fetching cluster client: %v", err)
+		return failed(fmt.Errorf("error fetching cluster client: %v", err))
 	}
 
-	rs = &RancherServer{
+	rs := &RancherServer{
+		Credentials:          credentials,
 		URL:                  url,
-		AccessKey:            accessKey,
-		SecretKey:            secretKey,
 		ClusterName:          clusterName,
-		TokenKey:             tokenKey,
 		ManagementClient:     mgmtClient,
 		DefaultClusterClient: clusterClient,
 		DefaultCluster:       &defaultCluster,
+		MintedToken:          mintedToken,
+		MintedClusterToken:   mintedClusterToken,
+		Backoff:              backoff,
+		apiClient:            apiClient,
 
 		APIEndPoint: apiEndpoint,
 	}
+	rs.KubeClient = NewKubeClient(rs.RESTConfig())
 
 	return rs, nil
 }
 
+// Close deletes the tokens minted for the run, if any. The server
+// cannot be used afterwards.
+func (rs *RancherServer) Close() error {
+	if rs.MintedClusterToken != nil {
+		if err := rs.ManagementClient.Token.Delete(rs.MintedClusterToken); err != nil {
+			return fmt.Errorf("error deleting token %v: %v", rs.MintedClusterToken.ID, err)
+		}
+		rs.MintedClusterToken = nil
+	}
+	if rs.MintedToken != nil {
+		if err := rs.ManagementClient.Token.Delete(rs.MintedToken); err != nil {
+			return fmt.Errorf("error deleting token %v: %v", rs.MintedToken.ID, err)
+		}
+		rs.MintedToken = nil
+	}
+	return nil
+}
+
+// ClientOpts returns the options of a norman client for the URL,
+// whose API calls are retried with the backoff of the server.
+func (rs *RancherServer) ClientOpts(url string) *normanclientbase.ClientOpts {
+	opts := rs.Credentials.ClientOpts(url)
+	if rs.apiClient != nil {
+		opts.HTTPClient = rs.apiClient
+	}
+	return opts
+}
+
+// clientBackoff returns the backoff of the retries of the norman client
+// with the options, or DefaultBackoff if it does not retry.
+func clientBackoff(opts *normanclientbase.ClientOpts) wait.Backoff {
+	if opts != nil && opts.HTTPClient != nil {
+		if t, ok := opts.HTTPClient.Transport.(*RetryTransport); ok {
+			return t.Backoff
+		}
+	}
+	return DefaultBackoff
+}
+
 func (rs *RancherServer) GetProjectAPIEndpointByID(projectID string) string {
 	return rs.APIEndPoint + "/projects/" + projectID
 }
 
 func (rs *RancherServer) GetProjectClientByID(projectID string) (*rprojectv3.Client, error) {
-	projectClientOpts := normanclientbase.ClientOpts{
-		URL:        rs.GetProjectAPIEndpointByID(projectID),
-		AccessKey:  rs.AccessKey,
-		SecretKey:  rs.SecretKey,
-		TokenKey:   rs.TokenKey,
-		HTTPClient: insecureClient,
-	}
-	return rprojectv3.NewClient(&projectClientOpts)
+	return rprojectv3.NewClient(rs.ClientOpts(rs.GetProjectAPIEndpointByID(projectID)))
+}
+
+// MoveNamespace moves the namespace into the project with the given ID,
+// or out of any project when the ID is empty, fetching the namespace
+// again when the move conflicts with another change.
+func (rs *RancherServer) MoveNamespace(ns *rclusterv3.Namespace, projectID string) error {
+	resp := map[string]interface{}{}
+	attempt := 0
+	return RetryOnConflict(rs.Backoff, func() error {
+		if attempt++; attempt > 1 {
+			current, err := rs.DefaultClusterClient.Namespace.ByID(ns.ID)
+			if err != nil {
+				return err
+			}
+			ns = current
+		}
+		return rs.DefaultClusterClient.Action(rclusterv3.NamespaceType, "move", &ns.Resource, map[string]string{"projectId": projectID}, &resp)
+	})
 }
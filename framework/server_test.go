@@ -1,9 +1,15 @@
 package framework
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 
+	normanclientbase "github.com/rancher/norman/clientbase"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func TestNewRancherServerFromEnvVars(t *testing.T) {
@@ -13,3 +19,58 @@ func TestNewRancherServerFromEnvVars(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestServerConfig(t *testing.T) {
+	rs := &RancherServer{
+		URL: "https://rancher.example.com",
+		Credentials: Credentials{
+			TokenKey:  "token-abcde:secret",
+			Username:  "admin",
+			Password:  "password",
+			ExpiresAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		DefaultCluster: &rmgmtv3.Cluster{Name: "local"},
+		Backoff:        testBackoff,
+	}
+
+	data, err := json.Marshal(rs.Config())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	config := ServerConfig{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	want := ServerConfig{
+		URL:         rs.URL,
+		ClusterName: "local",
+		Credentials: Credentials{TokenKey: rs.TokenKey, ExpiresAt: rs.ExpiresAt},
+		Backoff:     testBackoff,
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+}
+
+func TestServerBackoff(t *testing.T) {
+	other := wait.Backoff{Duration: time.Second, Factor: 3, Steps: 7}
+	servers := []*RancherServer{
+		{URL: "https://rancher.example.com", Backoff: testBackoff, apiClient: newAPIClient(testBackoff)},
+		{URL: "https://rancher.example.com", Backoff: other, apiClient: newAPIClient(other)},
+	}
+	for _, rs := range servers {
+		rs.DefaultCluster = &rmgmtv3.Cluster{}
+		opts := rs.ClientOpts(rs.URL + "/v3")
+		if got := clientBackoff(opts); got != rs.Backoff {
+			t.Errorf("norman client backoff = %+v, want %+v", got, rs.Backoff)
+		}
+		rt := rs.RESTConfig().WrapTransport(insecureTransport)
+		if got := rt.(*RetryTransport).Backoff; got != rs.Backoff {
+			t.Errorf("Kubernetes client backoff = %+v, want %+v", got, rs.Backoff)
+		}
+	}
+	if got := clientBackoff(&normanclientbase.ClientOpts{HTTPClient: insecureClient}); got != DefaultBackoff {
+		t.Errorf("the backoff of the logins changed to %+v", got)
+	}
+}
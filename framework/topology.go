@@ -0,0 +1,291 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+
+	normanclientbase "github.com/rancher/norman/clientbase"
+	normantypes "github.com/rancher/norman/types"
+	rclusterv3 "github.com/rancher/types/client/cluster/v3"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// TopologySpec lists the namespaces of each project of a topology, by
+// project name, and the probe workloads of each namespace.
+type TopologySpec map[string]map[string][]string
+
+// ProjectNames returns the names of the projects of the spec in order.
+func (s TopologySpec) ProjectNames() []string {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NamespaceNames returns the names of the namespaces of the project in
+// order.
+func (s TopologySpec) NamespaceNames(project string) []string {
+	var names []string
+	for name := range s[project] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Topology is a set of projects, namespaces and probe workloads which
+// is built once and shared by several specs. Specs which change it must
+// Reset it afterwards.
+type Topology struct {
+	Spec TopologySpec
+
+	server     *RancherServer
+	projects   map[string]*rmgmtv3.Project
+	clients    map[string]*rprojectv3.Client
+	namespaces map[string]*rclusterv3.Namespace
+	workloads  map[string]*rprojectv3.Workload
+}
+
+// BuildTopology creates the projects, namespaces and workloads of the
+// spec, and waits for them to become active and for the services of
+// the workloads to be ready. The topology is returned
+// even when building it fails, so that it can be deleted.
+func BuildTopology(rs *RancherServer, spec TopologySpec) (*Topology, error) {
+	t := &Topology{
+		Spec:       spec,
+		server:     rs,
+		projects:   map[string]*rmgmtv3.Project{},
+		clients:    map[string]*rprojectv3.Client{},
+		namespaces: map[string]*rclusterv3.Namespace{},
+		workloads:  map[string]*rprojectv3.Workload{},
+	}
+
+	for _, projectName := range spec.ProjectNames() {
+		project, err := rs.CreateProject(projectName)
+		if project != nil {
+			t.projects[projectName] = project
+		}
+		if err != nil {
+			return t, err
+		}
+		client, err := rs.GetProjectClientByID(project.ID)
+		if err != nil {
+			return t, fmt.Errorf("error creating client for project %v: %v", projectName, err)
+		}
+		t.clients[projectName] = client
+
+		for _, nsName := range spec.NamespaceNames(projectName) {
+			ns, err := rs.CreateNamespace(nsName, project.ID, nil)
+			if err != nil {
+				return t, err
+			}
+			t.namespaces[nsName] = ns
+
+			for _, workloadName := range spec[projectName][nsName] {
+				w, err := CreateWorkload(client, NewProbeWorkload(workloadName, nsName, nil))
+				if err != nil {
+					return t, err
+				}
+				t.workloads[nsName+"/"+workloadName] = w
+			}
+		}
+	}
+
+	for key, w := range t.workloads {
+		if err := rs.WaitForServiceReady(w.NamespaceId, w.Name, DefaultWaitTimeout); err != nil {
+			return t, fmt.Errorf("workload %v: %v", key, err)
+		}
+	}
+	return t, nil
+}
+
+// Project returns the project with the name.
+func (t *Topology) Project(name string) *rmgmtv3.Project {
+	return t.projects[name]
+}
+
+// ProjectClient returns the client of the project with the name.
+func (t *Topology) ProjectClient(name string) *rprojectv3.Client {
+	return t.clients[name]
+}
+
+// Namespace returns the namespace with the name.
+func (t *Topology) Namespace(name string) *rclusterv3.Namespace {
+	return t.namespaces[name]
+}
+
+// Workload returns the workload with the name in the namespace.
+func (t *Topology) Workload(namespace, name string) *rprojectv3.Workload {
+	return t.workloads[namespace+"/"+name]
+}
+
+// Endpoints returns the endpoints of all the workloads of the topology.
+func (t *Topology) Endpoints() ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, projectName := range t.Spec.ProjectNames() {
+		for _, nsName := range t.Spec.NamespaceNames(projectName) {
+			for _, workloadName := range t.Spec[projectName][nsName] {
+				e, err := GetWorkloadEndpoint(t.clients[projectName], t.workloads[nsName+"/"+workloadName])
+				if err != nil {
+					return nil, err
+				}
+				endpoints = append(endpoints, e)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// CountNetworkPolicies returns the number of NetworkPolicies in the
+// namespaces of the topology, including the ones Rancher generates.
+func (t *Topology) CountNetworkPolicies() (int, error) {
+	count := 0
+	for nsName := range t.namespaces {
+		policies, err := t.server.ListNetworkPolicies(nsName)
+		if err != nil {
+			return 0, err
+		}
+		count += len(policies.Items)
+	}
+	return count, nil
+}
+
+// Verify checks that the projects and namespaces of the topology are
+// active, that the namespaces are in the projects of the spec, and that
+// every workload is active with a running pod behind a ready service.
+func (t *Topology) Verify() error {
+	for projectName, namespaces := range t.Spec {
+		project := t.projects[projectName]
+		if project == nil {
+			return fmt.Errorf("project %v was not created", projectName)
+		}
+		p, err := t.server.ManagementClient.Project.ByID(project.ID)
+		if err != nil {
+			return fmt.Errorf("error fetching project %v: %v", projectName, err)
+		}
+		if p.State != "active" {
+			return fmt.Errorf("project %v is %v", projectName, p.State)
+		}
+
+		client := t.clients[projectName]
+		for nsName, workloads := range namespaces {
+			if t.namespaces[nsName] == nil {
+				return fmt.Errorf("namespace %v was not created", nsName)
+			}
+			ns, err := t.server.DefaultClusterClient.Namespace.ByID(t.namespaces[nsName].ID)
+			if err != nil {
+				return fmt.Errorf("error fetching namespace %v: %v", nsName, err)
+			}
+			if ns.State != "active" {
+				return fmt.Errorf("namespace %v is %v", nsName, ns.State)
+			}
+			if ns.ProjectID != project.ID {
+				return fmt.Errorf("namespace %v is in project %v instead of %v", nsName, ns.ProjectID, project.ID)
+			}
+
+			for _, workloadName := range workloads {
+				if err := t.verifyWorkload(client, t.workloads[nsName+"/"+workloadName]); err != nil {
+					return fmt.Errorf("workload %v/%v: %v", nsName, workloadName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// verifyWorkload checks that the workload is active with a running pod,
+// and that its service is ready.
+func (t *Topology) verifyWorkload(client *rprojectv3.Client, workload *rprojectv3.Workload) error {
+	if workload == nil {
+		return fmt.Errorf("not created")
+	}
+	w, err := client.Workload.ByID(workload.ID)
+	if err != nil {
+		return err
+	}
+	if w.State != "active" {
+		return fmt.Errorf("is %v", w.State)
+	}
+
+	pods, err := client.Pod.List(&normantypes.ListOpts{
+		Filters: map[string]interface{}{
+			"workloadId": w.ID,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	running := false
+	for _, pod := range pods.Data {
+		running = running || pod.State == "running"
+	}
+	if !running {
+		return fmt.Errorf("no running pod")
+	}
+	if reason := t.server.serviceNotReady(w.NamespaceId, w.Name); reason != "" {
+		return fmt.Errorf("service not ready: %v", reason)
+	}
+	return nil
+}
+
+// Reset undoes what specs are expected to change: it moves the
+// namespaces back into the projects of the spec, and deletes the
+// NetworkPolicies labelled with TestResourceLabel from them.
+func (t *Topology) Reset() error {
+	for projectName, namespaces := range t.Spec {
+		project := t.projects[projectName]
+		for nsName := range namespaces {
+			ns, err := t.server.DefaultClusterClient.Namespace.ByID(t.namespaces[nsName].ID)
+			if err != nil {
+				return fmt.Errorf("error fetching namespace %v: %v", nsName, err)
+			}
+			if ns.ProjectID != project.ID {
+				if err := t.server.MoveNamespace(ns, project.ID); err != nil {
+					return fmt.Errorf("error moving namespace %v back into project %v: %v", nsName, projectName, err)
+				}
+			}
+
+			policies, err := t.server.ListNetworkPolicies(nsName)
+			if err != nil {
+				return err
+			}
+			for _, np := range policies.Items {
+				if np.Labels[TestResourceLabel] != "true" {
+					continue
+				}
+				if err := t.server.DeleteObject(NetworkPolicyResource, nsName, np.Name); err != nil {
+					return fmt.Errorf("error deleting network policy %v/%v: %v", nsName, np.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Delete deletes the projects of the topology and waits for their
+// namespaces to be gone, so that the topology can be built again.
+func (t *Topology) Delete() error {
+	var errs []error
+	for name, project := range t.projects {
+		if err := t.server.ManagementClient.Project.Delete(project); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting project %v: %v", name, err))
+		}
+	}
+	for name, ns := range t.namespaces {
+		err := wait.PollImmediate(waitInterval, DefaultWaitTimeout, func() (bool, error) {
+			_, err := t.server.DefaultClusterClient.Namespace.ByID(ns.ID)
+			return normanclientbase.IsNotFound(err), nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error waiting for namespace %v to be deleted: %v", name, err))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("error deleting topology: %v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package framework
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	normantypes "github.com/rancher/norman/types"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+)
+
+const (
+	// UserGlobalRole is the global role which lets standard users log in.
+	UserGlobalRole = "user"
+	// ProjectMemberRole is the role template of project members.
+	ProjectMemberRole = "project-member"
+	// ClusterMemberRole is the role template of cluster members.
+	ClusterMemberRole = "cluster-member"
+)
+
+// TestUser is a standard user created by the tests, along with its
+// password.
+type TestUser struct {
+	*rmgmtv3.User
+	Password string
+}
+
+// CreateUser creates a standard user labelled with TestResourceLabel,
+// with a random password. The user has no access to any cluster or
+// project until it is bound to roles.
+func (rs *RancherServer) CreateUser(username string) (*TestUser, error) {
+	password, err := randomPassword()
+	if err != nil {
+		return nil, err
+	}
+	var user *rmgmtv3.User
+	err = RetryCreate(rs.Backoff, func() error {
+		var err error
+		user, err = rs.ManagementClient.User.Create(&rmgmtv3.User{
+			Username:           username,
+			Name:               username,
+			Password:           password,
+			MustChangePassword: false,
+			Labels:             TestResourceLabels(),
+		})
+		return err
+	}, func() (bool, error) {
+		collection, err := rs.ManagementClient.User.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"username": username},
+		})
+		if err != nil || len(collection.Data) == 0 {
+			return false, err
+		}
+		user = &collection.Data[0]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating user %v: %v", username, err)
+	}
+
+	err = RetryCreate(rs.Backoff, func() error {
+		_, err := rs.ManagementClient.GlobalRoleBinding.Create(&rmgmtv3.GlobalRoleBinding{
+			GlobalRoleId: UserGlobalRole,
+			UserId:       user.ID,
+			Labels:       TestResourceLabels(),
+		})
+		return err
+	}, func() (bool, error) {
+		collection, err := rs.ManagementClient.GlobalRoleBinding.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"globalRoleId": UserGlobalRole, "userId": user.ID},
+		})
+		return err == nil && len(collection.Data) > 0, err
+	})
+	if err != nil {
+		return &TestUser{User: user, Password: password}, fmt.Errorf("error binding user %v to global role %v: %v", username, UserGlobalRole, err)
+	}
+	return &TestUser{User: user, Password: password}, nil
+}
+
+// BindProjectRole binds the user to the role template in the project.
+func (rs *RancherServer) BindProjectRole(user *TestUser, projectID, roleTemplateID string) (*rmgmtv3.ProjectRoleTemplateBinding, error) {
+	var binding *rmgmtv3.ProjectRoleTemplateBinding
+	err := RetryCreate(rs.Backoff, func() error {
+		var err error
+		binding, err = rs.ManagementClient.ProjectRoleTemplateBinding.Create(&rmgmtv3.ProjectRoleTemplateBinding{
+			ProjectId:      projectID,
+			RoleTemplateId: roleTemplateID,
+			UserId:         user.ID,
+			Labels:         TestResourceLabels(),
+		})
+		return err
+	}, func() (bool, error) {
+		collection, err := rs.ManagementClient.ProjectRoleTemplateBinding.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"projectId": projectID, "roleTemplateId": roleTemplateID, "userId": user.ID},
+		})
+		if err != nil || len(collection.Data) == 0 {
+			return false, err
+		}
+		binding = &collection.Data[0]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error binding user %v to role %v in project %v: %v", user.Username, roleTemplateID, projectID, err)
+	}
+	return binding, nil
+}
+
+// BindClusterRole binds the user to the role template in the default
+// cluster.
+func (rs *RancherServer) BindClusterRole(user *TestUser, roleTemplateID string) (*rmgmtv3.ClusterRoleTemplateBinding, error) {
+	var binding *rmgmtv3.ClusterRoleTemplateBinding
+	err := RetryCreate(rs.Backoff, func() error {
+		var err error
+		binding, err = rs.ManagementClient.ClusterRoleTemplateBinding.Create(&rmgmtv3.ClusterRoleTemplateBinding{
+			ClusterId:      rs.DefaultCluster.ID,
+			RoleTemplateId: roleTemplateID,
+			UserId:         user.ID,
+			Labels:         TestResourceLabels(),
+		})
+		return err
+	}, func() (bool, error) {
+		collection, err := rs.ManagementClient.ClusterRoleTemplateBinding.List(&normantypes.ListOpts{
+			Filters: map[string]interface{}{"clusterId": rs.DefaultCluster.ID, "roleTemplateId": roleTemplateID, "userId": user.ID},
+		})
+		if err != nil || len(collection.Data) == 0 {
+			return false, err
+		}
+		binding = &collection.Data[0]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error binding user %v to role %v in cluster %v: %v", user.Username, roleTemplateID, rs.DefaultCluster.ID, err)
+	}
+	return binding, nil
+}
+
+// LoginAs returns a RancherServer for the same default cluster, whose
+// clients act as the user. It holds a token minted for the user, which
+// its Close deletes.
+func (rs *RancherServer) LoginAs(user *TestUser) (*RancherServer, error) {
+	userServer, err := newRancherServer(rs.URL, rs.DefaultCluster.Name, Credentials{
+		Username: user.Username,
+		Password: user.Password,
+	}, rs.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("error logging in as user %v: %v", user.Username, err)
+	}
+	userServer.Transcripts = rs.Transcripts
+	return userServer, nil
+}
+
+// DeleteUser deletes the user, along with its role bindings and tokens.
+func (rs *RancherServer) DeleteUser(user *TestUser) error {
+	if err := rs.ManagementClient.User.Delete(user.User); err != nil {
+		return fmt.Errorf("error deleting user %v: %v", user.Username, err)
+	}
+	return nil
+}
+
+// ListTestUsers returns the users which carry TestResourceLabel.
+func (rs *RancherServer) ListTestUsers() ([]rmgmtv3.User, error) {
+	collection, err := rs.ManagementClient.User.List(&normantypes.ListOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing users: %v", err)
+	}
+
+	var users []rmgmtv3.User
+	for collection != nil {
+		for _, u := range collection.Data {
+			if u.Labels[TestResourceLabel] == "true" {
+				users = append(users, u)
+			}
+		}
+		collection, err = collection.Next()
+		if err != nil {
+			return nil, fmt.Errorf("error listing users: %v", err)
+		}
+	}
+	return users, nil
+}
+
+// randomPassword returns a password for a test user.
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating password: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,9 +1,58 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/sirupsen/logrus"
 )
 
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"run", "execute the selected suites", runCommand},
+	{"probe", "check connectivity from one workload to another", probeCommand},
+	{"matrix", "print the reachability matrix of a set of namespaces", matrixCommand},
+	{"cleanup", "delete the resources left over by the tests", cleanupCommand},
+	{"validate", "check the configuration and the credentials", validateCommand},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %v <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %v\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nThe Rancher server is configured with the RANCHER_SERVER_URL, RANCHER_ACCESS_KEY,\n"+
+		"RANCHER_SECRET_KEY, RANCHER_TOKEN and RANCHER_DEFAULT_CLUSTER_NAME environment variables.\n"+
+		"Run '%v <command> --help' for the flags of a command.\n", os.Args[0])
+}
+
 func main() {
-	logrus.Infof("Hello World!")
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(os.Args[2:]); err != nil {
+				logrus.Fatalf("%v: %v", name, err)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/spf13/pflag"
+)
+
+func matrixCommand(args []string) error {
+	flags := pflag.NewFlagSet("matrix", pflag.ExitOnError)
+	namespaces := flags.StringSlice("namespace", nil, "namespaces whose workloads are probed")
+	projects := flags.StringSlice("project", nil, "IDs of the projects whose workloads are probed")
+	via := flags.String("via", string(framework.ViaDNS), "address of the destinations: pod-ip, cluster-ip or dns")
+	timeout := flags.Duration("timeout", framework.DefaultProbeTimeout, "probe timeout")
+	flags.Parse(args)
+
+	if len(*namespaces) == 0 && len(*projects) == 0 {
+		return fmt.Errorf("at least one --namespace or --project is required")
+	}
+	v, err := framework.ParseVia(*via)
+	if err != nil {
+		return fmt.Errorf("--via: %v", err)
+	}
+
+	rs, err := framework.NewRancherServerFromEnvVars()
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	for _, p := range *projects {
+		names, err := rs.ListProjectNamespaces(p)
+		if err != nil {
+			return err
+		}
+		*namespaces = append(*namespaces, names...)
+	}
+
+	var endpoints []framework.Endpoint
+	for _, ns := range *namespaces {
+		e, err := rs.ListNamespaceEndpoints(ns)
+		if err != nil {
+			return err
+		}
+		endpoints = append(endpoints, e...)
+	}
+	if len(endpoints) < 2 {
+		return fmt.Errorf("found %v workloads, at least two are needed", len(endpoints))
+	}
+	if v != framework.ViaDNS {
+		if endpoints, err = rs.ResolveEndpoints(endpoints); err != nil {
+			return err
+		}
+	}
+
+	prober := framework.NewProber(rs)
+	prober.Timeout = *timeout
+	observed, _ := prober.ProbeMatrixVia(endpoints, v)
+
+	fmt.Print(observed)
+	fmt.Println("\n'.' allowed, 'X' denied, rows are sources and columns destinations")
+	return nil
+}
@@ -0,0 +1,260 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Namespace is a namespace as seen by the evaluator.
+type Namespace struct {
+	Name   string
+	Labels map[string]string
+}
+
+// Pod is a pod as seen by the evaluator. NamedPorts maps the
+// names of the container ports to their numbers.
+type Pod struct {
+	Namespace  string
+	Name       string
+	Labels     map[string]string
+	IP         string
+	NamedPorts map[string]int32
+}
+
+// Key returns the key of the pod in a Matrix.
+func (p Pod) Key() string {
+	return p.Namespace + "/" + p.Name
+}
+
+// Port is the destination port traffic is evaluated for.
+type Port struct {
+	Protocol corev1.Protocol
+	Port     int32
+}
+
+// DefaultPort is the port the probes are sent to.
+var DefaultPort = Port{Protocol: corev1.ProtocolTCP, Port: 80}
+
+// Evaluator computes the expected reachability between pods
+// from a set of networking/v1 NetworkPolicies.
+type Evaluator struct {
+	namespaces map[string]labels.Set
+	policies   []networkingv1.NetworkPolicy
+}
+
+// NewEvaluator returns an Evaluator for the given namespaces
+// and policies.
+func NewEvaluator(namespaces []Namespace, policies []networkingv1.NetworkPolicy) *Evaluator {
+	e := &Evaluator{
+		namespaces: map[string]labels.Set{},
+		policies:   policies,
+	}
+	for _, ns := range namespaces {
+		e.namespaces[ns.Name] = labels.Set(ns.Labels)
+	}
+	return e
+}
+
+// Allowed returns whether traffic from src to dst on the port
+// is allowed by the policies.
+func (e *Evaluator) Allowed(src, dst Pod, port Port) (bool, error) {
+	egress, err := e.egressAllowed(src, dst, port)
+	if err != nil || !egress {
+		return false, err
+	}
+	return e.ingressAllowed(src, dst, port)
+}
+
+// Matrix returns the expected reachability between every
+// ordered pair of distinct pods.
+func (e *Evaluator) Matrix(pods []Pod, port Port) (Matrix, error) {
+	m := Matrix{}
+	for _, src := range pods {
+		for _, dst := range pods {
+			if src.Key() == dst.Key() {
+				continue
+			}
+			allowed, err := e.Allowed(src, dst, port)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(src.Key(), dst.Key(), allowed)
+		}
+	}
+	return m, nil
+}
+
+func (e *Evaluator) ingressAllowed(src, dst Pod, port Port) (bool, error) {
+	isolated := false
+	for _, np := range e.policies {
+		selected, err := e.selects(np, dst, networkingv1.PolicyTypeIngress)
+		if err != nil {
+			return false, err
+		}
+		if !selected {
+			continue
+		}
+		isolated = true
+		for _, rule := range np.Spec.Ingress {
+			peer, err := e.peersMatch(rule.From, np.Namespace, src)
+			if err != nil {
+				return false, err
+			}
+			if peer && portsMatch(rule.Ports, dst, port) {
+				return true, nil
+			}
+		}
+	}
+	return !isolated, nil
+}
+
+func (e *Evaluator) egressAllowed(src, dst Pod, port Port) (bool, error) {
+	isolated := false
+	for _, np := range e.policies {
+		selected, err := e.selects(np, src, networkingv1.PolicyTypeEgress)
+		if err != nil {
+			return false, err
+		}
+		if !selected {
+			continue
+		}
+		isolated = true
+		for _, rule := range np.Spec.Egress {
+			peer, err := e.peersMatch(rule.To, np.Namespace, dst)
+			if err != nil {
+				return false, err
+			}
+			if peer && portsMatch(rule.Ports, dst, port) {
+				return true, nil
+			}
+		}
+	}
+	return !isolated, nil
+}
+
+// selects returns whether the policy applies to the pod for
+// the given direction of traffic.
+func (e *Evaluator) selects(np networkingv1.NetworkPolicy, pod Pod, policyType networkingv1.PolicyType) (bool, error) {
+	if np.Namespace != pod.Namespace || !hasPolicyType(np, policyType) {
+		return false, nil
+	}
+	return selectorMatches(&np.Spec.PodSelector, pod.Labels, "podSelector of "+np.Name)
+}
+
+// hasPolicyType returns whether the policy isolates the given
+// direction. When policyTypes is not set, policies always isolate
+// ingress and only isolate egress when they have egress rules.
+func hasPolicyType(np networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(np.Spec.PolicyTypes) == 0 {
+		return policyType == networkingv1.PolicyTypeIngress || len(np.Spec.Egress) > 0
+	}
+	for _, t := range np.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// peersMatch returns whether the pod matches any of the peers of a
+// rule of a policy in policyNamespace. An empty list matches all pods.
+func (e *Evaluator) peersMatch(peers []networkingv1.NetworkPolicyPeer, policyNamespace string, pod Pod) (bool, error) {
+	if len(peers) == 0 {
+		return true, nil
+	}
+	for _, peer := range peers {
+		match, err := e.peerMatches(peer, policyNamespace, pod)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
+func (e *Evaluator) peerMatches(peer networkingv1.NetworkPolicyPeer, policyNamespace string, pod Pod) (bool, error) {
+	if peer.IPBlock != nil {
+		return ipBlockMatches(peer.IPBlock, pod.IP)
+	}
+
+	if peer.NamespaceSelector != nil {
+		nsLabels, ok := e.namespaces[pod.Namespace]
+		if !ok {
+			return false, fmt.Errorf("namespace %v of pod %v is unknown", pod.Namespace, pod.Name)
+		}
+		match, err := selectorMatches(peer.NamespaceSelector, nsLabels, "namespaceSelector")
+		if err != nil || !match {
+			return false, err
+		}
+	} else if pod.Namespace != policyNamespace {
+		return false, nil
+	}
+
+	if peer.PodSelector != nil {
+		return selectorMatches(peer.PodSelector, pod.Labels, "podSelector")
+	}
+	return true, nil
+}
+
+func selectorMatches(ls *metav1.LabelSelector, l map[string]string, what string) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return false, fmt.Errorf("invalid selector in %v: %v", what, err)
+	}
+	return selector.Matches(labels.Set(l)), nil
+}
+
+func ipBlockMatches(block *networkingv1.IPBlock, ip string) (bool, error) {
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil {
+		return false, fmt.Errorf("invalid ipBlock cidr %v: %v", block.CIDR, err)
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil || !cidr.Contains(addr) {
+		return false, nil
+	}
+	for _, except := range block.Except {
+		_, exceptCIDR, err := net.ParseCIDR(except)
+		if err != nil {
+			return false, fmt.Errorf("invalid ipBlock except %v: %v", except, err)
+		}
+		if exceptCIDR.Contains(addr) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// portsMatch returns whether the port matches any of the rule's ports.
+// An empty list matches all ports.
+func portsMatch(ports []networkingv1.NetworkPolicyPort, dst Pod, port Port) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		protocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			protocol = *p.Protocol
+		}
+		if protocol != port.Protocol {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		if p.Port.Type == intstr.Int && p.Port.IntVal == port.Port {
+			return true
+		}
+		if p.Port.Type == intstr.String {
+			if number, ok := dst.NamedPorts[p.Port.StrVal]; ok && number == port.Port {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestProjectIsolationMatrix(t *testing.T) {
+	namespaces := []Namespace{
+		ProjectNamespace("ns1-alpha", "c-abcde:p-alpha", nil),
+		ProjectNamespace("ns2-alpha", "c-abcde:p-alpha", nil),
+		ProjectNamespace("ns1-bravo", "c-abcde:p-bravo", nil),
+		{Name: "unmanaged"},
+	}
+	policies := []networkingv1.NetworkPolicy{
+		ProjectIsolationPolicy("ns1-alpha", "c-abcde:p-alpha"),
+		ProjectIsolationPolicy("ns2-alpha", "c-abcde:p-alpha"),
+		ProjectIsolationPolicy("ns1-bravo", "c-abcde:p-bravo"),
+	}
+	pods := []Pod{
+		{Namespace: "ns1-alpha", Name: "w1"},
+		{Namespace: "ns2-alpha", Name: "w2"},
+		{Namespace: "ns1-bravo", Name: "w3"},
+		{Namespace: "unmanaged", Name: "w4"},
+	}
+
+	m, err := NewEvaluator(namespaces, policies).Matrix(pods, DefaultPort)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := Matrix{}
+	expected.Set("ns1-alpha/w1", "ns2-alpha/w2", true)
+	expected.Set("ns1-alpha/w1", "ns1-bravo/w3", false)
+	expected.Set("ns1-alpha/w1", "unmanaged/w4", true)
+	expected.Set("ns2-alpha/w2", "ns1-alpha/w1", true)
+	expected.Set("ns2-alpha/w2", "ns1-bravo/w3", false)
+	expected.Set("ns2-alpha/w2", "unmanaged/w4", true)
+	expected.Set("ns1-bravo/w3", "ns1-alpha/w1", false)
+	expected.Set("ns1-bravo/w3", "ns2-alpha/w2", false)
+	expected.Set("ns1-bravo/w3", "unmanaged/w4", true)
+	expected.Set("unmanaged/w4", "ns1-alpha/w1", false)
+	expected.Set("unmanaged/w4", "ns2-alpha/w2", false)
+	expected.Set("unmanaged/w4", "ns1-bravo/w3", false)
+
+	if diff := expected.Diff(m); len(diff) != 0 {
+		t.Errorf("unexpected matrix:\n%v\nmismatches: %v", m, diff)
+	}
+	if _, ok := m.Get("ns1-alpha/w1", "ns1-alpha/w1"); ok {
+		t.Errorf("expected the matrix not to contain self pairs")
+	}
+}
+
+func TestPodSelectorAndPorts(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	httpPort := intstr.FromInt(80)
+	namedPort := intstr.FromString("metrics")
+
+	namespaces := []Namespace{{Name: "ns", Labels: map[string]string{"team": "a"}}}
+	policies := []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-frontend", Namespace: "ns"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "backend"}},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From: []networkingv1.NetworkPolicyPeer{
+							{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}},
+						},
+						Ports: []networkingv1.NetworkPolicyPort{
+							{Protocol: &tcp, Port: &httpPort},
+							{Port: &namedPort},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	frontend := Pod{Namespace: "ns", Name: "frontend", Labels: map[string]string{"app": "frontend"}}
+	other := Pod{Namespace: "ns", Name: "other", Labels: map[string]string{"app": "other"}}
+	backend := Pod{Namespace: "ns", Name: "backend", Labels: map[string]string{"app": "backend"}, NamedPorts: map[string]int32{"metrics": 9090}}
+
+	e := NewEvaluator(namespaces, policies)
+	tests := []struct {
+		src, dst Pod
+		port     Port
+		expected bool
+	}{
+		{frontend, backend, DefaultPort, true},
+		{frontend, backend, Port{Protocol: corev1.ProtocolTCP, Port: 9090}, true},
+		{frontend, backend, Port{Protocol: corev1.ProtocolTCP, Port: 8080}, false},
+		{frontend, backend, Port{Protocol: corev1.ProtocolUDP, Port: 80}, false},
+		{other, backend, DefaultPort, false},
+		{backend, other, DefaultPort, true},
+	}
+	for _, test := range tests {
+		actual, err := e.Allowed(test.src, test.dst, test.port)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if actual != test.expected {
+			t.Errorf("%v -> %v on %v: expected %v, got %v", test.src.Key(), test.dst.Key(), test.port, test.expected, actual)
+		}
+	}
+}
+
+func TestEgressAndIPBlock(t *testing.T) {
+	namespaces := []Namespace{{Name: "ns"}}
+	policies := []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "egress-to-block", Namespace: "ns"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "client"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{
+						To: []networkingv1.NetworkPolicyPeer{
+							{IPBlock: &networkingv1.IPBlock{CIDR: "10.42.0.0/16", Except: []string{"10.42.1.0/24"}}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	client := Pod{Namespace: "ns", Name: "client", Labels: map[string]string{"app": "client"}, IP: "10.42.0.5"}
+	inBlock := Pod{Namespace: "ns", Name: "in-block", IP: "10.42.0.6"}
+	excepted := Pod{Namespace: "ns", Name: "excepted", IP: "10.42.1.6"}
+	outside := Pod{Namespace: "ns", Name: "outside", IP: "10.43.0.6"}
+
+	e := NewEvaluator(namespaces, policies)
+	tests := []struct {
+		src, dst Pod
+		expected bool
+	}{
+		{client, inBlock, true},
+		{client, excepted, false},
+		{client, outside, false},
+		{outside, client, true},
+	}
+	for _, test := range tests {
+		actual, err := e.Allowed(test.src, test.dst, DefaultPort)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if actual != test.expected {
+			t.Errorf("%v -> %v: expected %v, got %v", test.src.Key(), test.dst.Key(), test.expected, actual)
+		}
+	}
+}
+
+func TestNamespaceAndPodSelector(t *testing.T) {
+	namespaces := []Namespace{
+		{Name: "ns", Labels: map[string]string{"env": "prod"}},
+		{Name: "monitoring", Labels: map[string]string{"purpose": "monitoring"}},
+	}
+	policies := []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-prometheus", Namespace: "ns"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From: []networkingv1.NetworkPolicyPeer{
+							{
+								NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"purpose": "monitoring"}},
+								PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	target := Pod{Namespace: "ns", Name: "target"}
+	prometheus := Pod{Namespace: "monitoring", Name: "prometheus", Labels: map[string]string{"app": "prometheus"}}
+	grafana := Pod{Namespace: "monitoring", Name: "grafana", Labels: map[string]string{"app": "grafana"}}
+	sameNamespace := Pod{Namespace: "ns", Name: "peer", Labels: map[string]string{"app": "prometheus"}}
+
+	e := NewEvaluator(namespaces, policies)
+	tests := []struct {
+		src      Pod
+		expected bool
+	}{
+		{prometheus, true},
+		{grafana, false},
+		{sameNamespace, false},
+	}
+	for _, test := range tests {
+		actual, err := e.Allowed(test.src, target, DefaultPort)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if actual != test.expected {
+			t.Errorf("%v -> %v: expected %v, got %v", test.src.Key(), target.Key(), test.expected, actual)
+		}
+	}
+
+	if _, err := e.Allowed(Pod{Namespace: "unknown", Name: "x"}, target, DefaultPort); err == nil {
+		t.Errorf("expected an error for a pod in an unknown namespace")
+	}
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Matrix holds, for ordered pairs of pod keys, whether traffic
+// from the first pod to the second is allowed.
+type Matrix map[string]map[string]bool
+
+// Set records whether traffic from src to dst is allowed.
+func (m Matrix) Set(src, dst string, allowed bool) {
+	if m[src] == nil {
+		m[src] = map[string]bool{}
+	}
+	m[src][dst] = allowed
+}
+
+// Get returns whether traffic from src to dst is allowed, and
+// whether the pair is part of the matrix at all.
+func (m Matrix) Get(src, dst string) (allowed bool, ok bool) {
+	allowed, ok = m[src][dst]
+	return allowed, ok
+}
+
+// Keys returns the sorted keys of all the pods in the matrix.
+func (m Matrix) Keys() []string {
+	seen := map[string]bool{}
+	for src, row := range m {
+		seen[src] = true
+		for dst := range row {
+			seen[dst] = true
+		}
+	}
+	var keys []string
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Mismatch is a pair whose observed reachability differs from
+// the expected one.
+type Mismatch struct {
+	From     string
+	To       string
+	Expected bool
+	Observed bool
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%v -> %v: expected %v, observed %v", m.From, m.To, verdict(m.Expected), verdict(m.Observed))
+}
+
+// Diff returns the pairs of the expected matrix m whose observed
+// reachability differs. Pairs missing from observed are ignored.
+func (m Matrix) Diff(observed Matrix) []Mismatch {
+	var result []Mismatch
+	keys := m.Keys()
+	for _, src := range keys {
+		for _, dst := range keys {
+			expected, ok := m.Get(src, dst)
+			if !ok {
+				continue
+			}
+			actual, ok := observed.Get(src, dst)
+			if !ok || actual == expected {
+				continue
+			}
+			result = append(result, Mismatch{From: src, To: dst, Expected: expected, Observed: actual})
+		}
+	}
+	return result
+}
+
+// String renders the matrix as a grid with sources as rows and
+// destinations as columns.
+func (m Matrix) String() string {
+	keys := m.Keys()
+	width := 0
+	for _, k := range keys {
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%-*s", width, "")
+	for i := range keys {
+		fmt.Fprintf(buf, " %3d", i)
+	}
+	buf.WriteString("\n")
+	for i, src := range keys {
+		fmt.Fprintf(buf, "%-*s", width, src)
+		for _, dst := range keys {
+			cell := "-"
+			if allowed, ok := m.Get(src, dst); ok {
+				cell = "X"
+				if allowed {
+					cell = "."
+				}
+			}
+			fmt.Fprintf(buf, " %3s", cell)
+		}
+		fmt.Fprintf(buf, "  (%d)\n", i)
+	}
+	return buf.String()
+}
+
+func verdict(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
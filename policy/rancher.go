@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ProjectIDLabel is the label Rancher sets on namespaces to the
+	// ID of the project they belong to, without the cluster prefix.
+	ProjectIDLabel = "field.cattle.io/projectId"
+
+	// ProjectIsolationPolicyName is the name of the NetworkPolicy
+	// Rancher creates in every namespace of a project.
+	ProjectIsolationPolicyName = "np-default"
+
+	// HostNetworkPolicyPrefix starts the names of the NetworkPolicies
+	// Rancher creates in every namespace of a project to allow the
+	// traffic from the host network of the nodes.
+	HostNetworkPolicyPrefix = "hn-"
+)
+
+// RancherGenerated returns whether the policy is one of the project
+// isolation or host network policies Rancher manages.
+func RancherGenerated(np networkingv1.NetworkPolicy) bool {
+	return np.Name == ProjectIsolationPolicyName || strings.HasPrefix(np.Name, HostNetworkPolicyPrefix)
+}
+
+// ProjectLabelValue returns the value of ProjectIDLabel for a Rancher
+// project ID, which is of the form <cluster-id>:<project-id>.
+func ProjectLabelValue(projectID string) string {
+	if i := strings.Index(projectID, ":"); i >= 0 {
+		return projectID[i+1:]
+	}
+	return projectID
+}
+
+// ProjectNamespace returns a namespace carrying the project label of
+// the given Rancher project, if any, in addition to the given labels.
+func ProjectNamespace(name, projectID string, labels map[string]string) Namespace {
+	l := map[string]string{}
+	for k, v := range labels {
+		l[k] = v
+	}
+	if projectID != "" {
+		l[ProjectIDLabel] = ProjectLabelValue(projectID)
+	}
+	return Namespace{Name: name, Labels: l}
+}
+
+// ProjectIsolationPolicy returns the NetworkPolicy Rancher generates in
+// a project namespace, which only allows ingress from namespaces of
+// the same project.
+func ProjectIsolationPolicy(namespace, projectID string) networkingv1.NetworkPolicy {
+	return networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ProjectIsolationPolicyName,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							NamespaceSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									ProjectIDLabel: ProjectLabelValue(projectID),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ProjectPolicies returns the NetworkPolicies Rancher is expected to
+// generate for the namespaces, given the project ID of each namespace.
+func ProjectPolicies(namespaceProjects map[string]string) []networkingv1.NetworkPolicy {
+	var policies []networkingv1.NetworkPolicy
+	for ns, projectID := range namespaceProjects {
+		policies = append(policies, ProjectIsolationPolicy(ns, projectID))
+	}
+	return policies
+}
+
+// OrphanedPolicy is a Rancher generated policy left in a namespace
+// which is no longer in its project.
+type OrphanedPolicy struct {
+	Namespace string
+	Name      string
+	Reason    string
+}
+
+func (o OrphanedPolicy) String() string {
+	return fmt.Sprintf("%v/%v: %v", o.Namespace, o.Name, o.Reason)
+}
+
+// OrphanedPolicies returns the Rancher generated policies of the
+// namespaces which are not in an existing project, and the project
+// isolation policies which allow another project than the one of their
+// namespace. Projects holds the values of ProjectIDLabel of the
+// existing projects. The policies of namespaces which are not given,
+// such as terminating ones, are ignored.
+func OrphanedPolicies(namespaces []Namespace, projects []string, policies []networkingv1.NetworkPolicy) []OrphanedPolicy {
+	labels := map[string]map[string]string{}
+	for _, ns := range namespaces {
+		labels[ns.Name] = ns.Labels
+	}
+	exists := map[string]bool{}
+	for _, p := range projects {
+		exists[p] = true
+	}
+
+	var result []OrphanedPolicy
+	for _, np := range policies {
+		nsLabels, ok := labels[np.Namespace]
+		if !ok || !RancherGenerated(np) {
+			continue
+		}
+		if reason := orphanReason(np, nsLabels, exists); reason != "" {
+			result = append(result, OrphanedPolicy{Namespace: np.Namespace, Name: np.Name, Reason: reason})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Namespace+"/"+result[i].Name < result[j].Namespace+"/"+result[j].Name
+	})
+	return result
+}
+
+func orphanReason(np networkingv1.NetworkPolicy, nsLabels map[string]string, exists map[string]bool) string {
+	project, ok := nsLabels[ProjectIDLabel]
+	switch {
+	case !ok:
+		return "namespace is not in a project"
+	case !exists[project]:
+		return fmt.Sprintf("project %v no longer exists", project)
+	}
+	for _, rule := range np.Spec.Ingress {
+		for _, peer := range rule.From {
+			if peer.NamespaceSelector == nil {
+				continue
+			}
+			if selected := peer.NamespaceSelector.MatchLabels[ProjectIDLabel]; selected != "" && selected != project {
+				return fmt.Sprintf("allows project %v, but the namespace is in project %v", selected, project)
+			}
+		}
+	}
+	return ""
+}
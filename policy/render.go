@@ -0,0 +1,196 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Render returns a compact, human readable form of the policy, one
+// line per rule. Only the fields which affect reachability are shown.
+func Render(np networkingv1.NetworkPolicy) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%v/%v\n", np.Namespace, np.Name)
+	fmt.Fprintf(buf, "  pods: %v\n", renderSelector(&np.Spec.PodSelector))
+
+	if hasPolicyType(np, networkingv1.PolicyTypeIngress) {
+		if len(np.Spec.Ingress) == 0 {
+			buf.WriteString("  ingress: deny all\n")
+		}
+		for _, rule := range np.Spec.Ingress {
+			fmt.Fprintf(buf, "  ingress: from %v on %v\n", renderPeers(rule.From), renderPorts(rule.Ports))
+		}
+	}
+	if hasPolicyType(np, networkingv1.PolicyTypeEgress) {
+		if len(np.Spec.Egress) == 0 {
+			buf.WriteString("  egress: deny all\n")
+		}
+		for _, rule := range np.Spec.Egress {
+			fmt.Fprintf(buf, "  egress: to %v on %v\n", renderPeers(rule.To), renderPorts(rule.Ports))
+		}
+	}
+	return buf.String()
+}
+
+// RenderAll renders the policies sorted by namespace and name.
+func RenderAll(policies []networkingv1.NetworkPolicy) string {
+	sorted := append([]networkingv1.NetworkPolicy(nil), policies...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return policyKey(sorted[i]) < policyKey(sorted[j])
+	})
+	var rendered []string
+	for _, np := range sorted {
+		rendered = append(rendered, Render(np))
+	}
+	return strings.Join(rendered, "")
+}
+
+func renderSelector(ls *metav1.LabelSelector) string {
+	selector, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return fmt.Sprintf("<invalid: %v>", err)
+	}
+	if selector.Empty() {
+		return "*"
+	}
+	return selector.String()
+}
+
+func renderPeers(peers []networkingv1.NetworkPolicyPeer) string {
+	if len(peers) == 0 {
+		return "any"
+	}
+	var rendered []string
+	for _, peer := range peers {
+		var parts []string
+		if peer.IPBlock != nil {
+			block := "ip(" + peer.IPBlock.CIDR
+			if len(peer.IPBlock.Except) > 0 {
+				block += " except " + strings.Join(peer.IPBlock.Except, ",")
+			}
+			parts = append(parts, block+")")
+		}
+		if peer.NamespaceSelector != nil {
+			parts = append(parts, "ns("+renderSelector(peer.NamespaceSelector)+")")
+		}
+		if peer.PodSelector != nil {
+			parts = append(parts, "pod("+renderSelector(peer.PodSelector)+")")
+		}
+		rendered = append(rendered, strings.Join(parts, " "))
+	}
+	return strings.Join(rendered, " | ")
+}
+
+func renderPorts(ports []networkingv1.NetworkPolicyPort) string {
+	if len(ports) == 0 {
+		return "any port"
+	}
+	var rendered []string
+	for _, p := range ports {
+		protocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			protocol = *p.Protocol
+		}
+		if p.Port == nil {
+			rendered = append(rendered, string(protocol))
+		} else {
+			rendered = append(rendered, fmt.Sprintf("%v/%v", protocol, p.Port.String()))
+		}
+	}
+	return strings.Join(rendered, ",")
+}
+
+func policyKey(np networkingv1.NetworkPolicy) string {
+	return np.Namespace + "/" + np.Name
+}
+
+// PolicyDiff is an expected policy which is missing or differs from
+// the actual one. Expected and Actual are the rendered policies, and
+// Actual is empty when the policy is missing.
+type PolicyDiff struct {
+	Namespace string
+	Name      string
+	Expected  string
+	Actual    string
+}
+
+func (d PolicyDiff) String() string {
+	if d.Actual == "" {
+		return fmt.Sprintf("missing %v/%v, expected:\n%v", d.Namespace, d.Name, d.Expected)
+	}
+	return fmt.Sprintf("%v/%v differs:\n%v", d.Namespace, d.Name, diffLines(d.Expected, d.Actual))
+}
+
+// DiffPolicies compares the actual policies with the expected ones by
+// namespace and name. Actual policies which are not expected, such as
+// the ones created by the tests, are ignored.
+func DiffPolicies(expected, actual []networkingv1.NetworkPolicy) []PolicyDiff {
+	byKey := map[string]networkingv1.NetworkPolicy{}
+	for _, np := range actual {
+		byKey[policyKey(np)] = np
+	}
+
+	var result []PolicyDiff
+	for _, np := range expected {
+		diff := PolicyDiff{Namespace: np.Namespace, Name: np.Name, Expected: Render(np)}
+		if a, ok := byKey[policyKey(np)]; ok {
+			diff.Actual = Render(a)
+			if diff.Actual == diff.Expected {
+				continue
+			}
+		}
+		result = append(result, diff)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Namespace+"/"+result[i].Name < result[j].Namespace+"/"+result[j].Name
+	})
+	return result
+}
+
+// diffLines returns a line diff of the texts, prefixing the removed
+// lines with '-', the added ones with '+' and the others with ' '.
+func diffLines(a, b string) string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence
+	// of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			fmt.Fprintf(buf, " %v\n", x[i])
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(buf, "-%v\n", x[i])
+			i++
+		default:
+			fmt.Fprintf(buf, "+%v\n", y[j])
+			j++
+		}
+	}
+	return buf.String()
+}
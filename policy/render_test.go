@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRender(t *testing.T) {
+	udp := corev1.ProtocolUDP
+	dns := intstr.FromInt(53)
+	np := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "egress", Namespace: "ns1"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{
+					To: []networkingv1.NetworkPolicyPeer{
+						{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+						{NamespaceSelector: &metav1.LabelSelector{}, PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}}},
+					},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &dns}},
+				},
+			},
+		},
+	}
+
+	expected := "ns1/egress\n" +
+		"  pods: app=web\n" +
+		"  egress: to ip(10.0.0.0/8 except 10.1.0.0/16) | ns(*) pod(k8s-app=kube-dns) on UDP/53\n"
+	if rendered := Render(np); rendered != expected {
+		t.Errorf("unexpected rendering:\n%v\nexpected:\n%v", rendered, expected)
+	}
+
+	expected = "ns1/np-default\n" +
+		"  pods: *\n" +
+		"  ingress: from ns(field.cattle.io/projectId=p-alpha) on any port\n"
+	if rendered := Render(ProjectIsolationPolicy("ns1", "c-abcde:p-alpha")); rendered != expected {
+		t.Errorf("unexpected rendering:\n%v\nexpected:\n%v", rendered, expected)
+	}
+}
+
+func TestDiffPolicies(t *testing.T) {
+	expected := ProjectPolicies(map[string]string{
+		"ns1": "c-abcde:p-alpha",
+		"ns2": "c-abcde:p-alpha",
+		"ns3": "c-abcde:p-bravo",
+	})
+	actual := []networkingv1.NetworkPolicy{
+		ProjectIsolationPolicy("ns1", "c-abcde:p-alpha"),
+		ProjectIsolationPolicy("ns2", "c-abcde:p-bravo"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "extra", Namespace: "ns1"}},
+	}
+
+	diffs := DiffPolicies(expected, actual)
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 differences, got %v", diffs)
+	}
+	if diffs[0].Namespace != "ns2" || !strings.Contains(diffs[0].String(), "-  ingress: from ns(field.cattle.io/projectId=p-alpha) on any port\n+  ingress: from ns(field.cattle.io/projectId=p-bravo) on any port\n") {
+		t.Errorf("unexpected difference: %v", diffs[0])
+	}
+	if diffs[1].Namespace != "ns3" || diffs[1].Actual != "" || !strings.HasPrefix(diffs[1].String(), "missing ns3/np-default") {
+		t.Errorf("unexpected difference: %v", diffs[1])
+	}
+}
+
+func TestOrphanedPolicies(t *testing.T) {
+	namespaces := []Namespace{
+		ProjectNamespace("ns1", "c-abcde:p-alpha", nil),
+		ProjectNamespace("ns2", "c-abcde:p-alpha", nil),
+		ProjectNamespace("ns3", "", nil),
+		ProjectNamespace("ns4", "c-abcde:p-gone", nil),
+	}
+	policies := []networkingv1.NetworkPolicy{
+		ProjectIsolationPolicy("ns1", "c-abcde:p-alpha"),
+		ProjectIsolationPolicy("ns2", "c-abcde:p-bravo"),
+		ProjectIsolationPolicy("ns3", "c-abcde:p-alpha"),
+		ProjectIsolationPolicy("ns4", "c-abcde:p-gone"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "extra", Namespace: "ns3"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "hn-nodes", Namespace: "ns1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "hn-nodes", Namespace: "ns3"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "hn-nodes", Namespace: "ns4"}},
+		ProjectIsolationPolicy("ns9", "c-abcde:p-gone"),
+	}
+
+	orphans := OrphanedPolicies(namespaces, []string{"p-alpha", "p-bravo"}, policies)
+	var got []string
+	for _, o := range orphans {
+		got = append(got, o.String())
+	}
+	expected := []string{
+		"ns2/np-default: allows project p-bravo, but the namespace is in project p-alpha",
+		"ns3/hn-nodes: namespace is not in a project",
+		"ns3/np-default: namespace is not in a project",
+		"ns4/hn-nodes: project p-gone no longer exists",
+		"ns4/np-default: project p-gone no longer exists",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected orphans:\n%v\nexpected:\n%v", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/spf13/pflag"
+)
+
+func probeCommand(args []string) error {
+	flags := pflag.NewFlagSet("probe", pflag.ExitOnError)
+	from := flags.String("from", "", "source workload, as <namespace>/<workload>")
+	to := flags.String("to", "", "destination workload, as <namespace>/<workload>")
+	expect := flags.String("expect", "", "fail unless the destination is \"allowed\" or \"denied\"")
+	via := flags.String("via", string(framework.ViaDNS), "address of the destination: pod-ip, cluster-ip or dns")
+	timeout := flags.Duration("timeout", framework.DefaultProbeTimeout, "probe timeout")
+	verbose := flags.BoolP("verbose", "v", false, "print the output of the probe")
+	flags.Parse(args)
+
+	if *expect != "" && *expect != "allowed" && *expect != "denied" {
+		return fmt.Errorf("--expect must be either allowed or denied")
+	}
+	v, err := framework.ParseVia(*via)
+	if err != nil {
+		return fmt.Errorf("--via: %v", err)
+	}
+
+	rs, err := framework.NewRancherServerFromEnvVars()
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+
+	src, err := getEndpoint(rs, *from)
+	if err != nil {
+		return fmt.Errorf("--from: %v", err)
+	}
+	dst, err := getEndpoint(rs, *to)
+	if err != nil {
+		return fmt.Errorf("--to: %v", err)
+	}
+	if v != framework.ViaDNS {
+		if dst, err = rs.ResolveEndpoint(dst); err != nil {
+			return fmt.Errorf("--to: %v", err)
+		}
+	}
+
+	prober := framework.NewProber(rs)
+	prober.Timeout = *timeout
+	result := prober.Probe(framework.Pair{From: src, To: dst, Via: v})
+
+	verdict := "denied"
+	if result.Reachable {
+		verdict = "allowed"
+	}
+	fmt.Printf("%v: %v, %v (%v)\n", result.Pair, verdict, result.Outcome, result.Latency.Round(time.Millisecond))
+	if *verbose {
+		fmt.Println(result.Output)
+	}
+	if result.Err != nil {
+		return fmt.Errorf("error running probe: %v", result.Err)
+	}
+	if !result.Outcome.Conclusive() {
+		return fmt.Errorf("probe failed: %v", result.Outcome)
+	}
+	if *expect != "" && *expect != verdict {
+		return fmt.Errorf("expected %v, got %v", *expect, verdict)
+	}
+	return nil
+}
+
+func getEndpoint(rs *framework.RancherServer, workload string) (framework.Endpoint, error) {
+	parts := strings.Split(workload, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return framework.Endpoint{}, fmt.Errorf("expected <namespace>/<workload>, got %q", workload)
+	}
+	return rs.GetEndpoint(parts[0], parts[1])
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/spf13/pflag"
+)
+
+func runCommand(args []string) error {
+	flags := pflag.NewFlagSet("run", pflag.ExitOnError)
+	dir := flags.String("dir", ".", "root of the test-network-policy source tree")
+	suites := flags.StringSlice("suite", nil, "suites to run, all of them when not set")
+	focus := flags.String("focus", "", "only run the specs matching this regular expression")
+	skip := flags.String("skip", "", "skip the specs matching this regular expression")
+	nodes := flags.Int("nodes", 1, "number of parallel ginkgo nodes, which needs the ginkgo command")
+	placements := flags.StringSlice("placement", nil, "placements the scenarios are run with: any-node, same-node or cross-node")
+	flags.Parse(args)
+
+	for _, p := range *placements {
+		if _, err := framework.ParsePlacement(p); err != nil {
+			return fmt.Errorf("--placement: %v", err)
+		}
+	}
+
+	if len(*suites) == 0 {
+		entries, err := ioutil.ReadDir(filepath.Join(*dir, "suites"))
+		if err != nil {
+			return fmt.Errorf("error listing suites: %v", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				*suites = append(*suites, e.Name())
+			}
+		}
+	}
+
+	var packages []string
+	for _, s := range *suites {
+		packages = append(packages, "./suites/"+s+"/")
+	}
+
+	var cmd *exec.Cmd
+	if *nodes > 1 {
+		ginkgoArgs := []string{"-v", "-nodes", strconv.Itoa(*nodes)}
+		if *focus != "" {
+			ginkgoArgs = append(ginkgoArgs, "-focus="+*focus)
+		}
+		if *skip != "" {
+			ginkgoArgs = append(ginkgoArgs, "-skip="+*skip)
+		}
+		cmd = exec.Command("ginkgo", append(ginkgoArgs, packages...)...)
+	} else {
+		testArgs := append([]string{"test", "-v", "-timeout", "0"}, packages...)
+		testArgs = append(testArgs, "-args")
+		if *focus != "" {
+			testArgs = append(testArgs, "-ginkgo.focus="+*focus)
+		}
+		if *skip != "" {
+			testArgs = append(testArgs, "-ginkgo.skip="+*skip)
+		}
+		cmd = exec.Command("go", testArgs...)
+	}
+	cmd.Dir = *dir
+	if len(*placements) != 0 {
+		cmd.Env = append(os.Environ(), "RANCHER_TEST_PLACEMENTS="+strings.Join(*placements, ","))
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("suites failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package scenario
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/test-network-policy/framework"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	rprojectv3 "github.com/rancher/types/client/project/v3"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// fixtures creates the projects, namespaces, workloads and policies
+// of scenarios in a cluster, and deletes them on cleanup.
+type fixtures interface {
+	// createProject creates the project and returns its ID.
+	createProject(name string) (string, error)
+	createNamespace(ns Namespace, projectID string) error
+	// createWorkload creates the workload, pinned to the node if not
+	// empty, and returns its endpoint.
+	createWorkload(namespace, projectID string, w Workload, node string) (framework.Endpoint, error)
+	createNetworkPolicy(np *networkingv1.NetworkPolicy) error
+	// waitForEndpoints waits for the services of the workloads of the
+	// endpoints to be ready.
+	waitForEndpoints(endpoints []framework.Endpoint, timeout time.Duration) error
+	// resolveEndpoints returns the endpoints with their pod IPs and
+	// ClusterIPs.
+	resolveEndpoints(endpoints []framework.Endpoint) ([]framework.Endpoint, error)
+	// listNodes returns the names of the schedulable nodes.
+	listNodes() ([]string, error)
+	cleanup() error
+}
+
+// rancherFixtures creates the fixtures through a Rancher server.
+type rancherFixtures struct {
+	server   *framework.RancherServer
+	projects []*rmgmtv3.Project
+	clients  map[string]*rprojectv3.Client
+}
+
+func (f *rancherFixtures) createProject(name string) (string, error) {
+	project, err := f.server.CreateProject(name)
+	if project != nil {
+		f.projects = append(f.projects, project)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	client, err := f.server.GetProjectClientByID(project.ID)
+	if err != nil {
+		return "", fmt.Errorf("error creating client for project %v: %v", name, err)
+	}
+	if f.clients == nil {
+		f.clients = map[string]*rprojectv3.Client{}
+	}
+	f.clients[project.ID] = client
+	return project.ID, nil
+}
+
+func (f *rancherFixtures) createNamespace(ns Namespace, projectID string) error {
+	_, err := f.server.CreateNamespace(ns.Name, projectID, ns.Labels)
+	return err
+}
+
+func (f *rancherFixtures) createWorkload(namespace, projectID string, w Workload, node string) (framework.Endpoint, error) {
+	client := f.clients[projectID]
+	workload := framework.NewProbeWorkload(w.Name, namespace, w.Labels)
+	if node != "" {
+		nodeID, err := f.server.GetNodeID(node)
+		if err != nil {
+			return framework.Endpoint{}, err
+		}
+		framework.PinWorkload(workload, nodeID)
+	}
+	workload, err := framework.CreateWorkload(client, workload)
+	if err != nil {
+		return framework.Endpoint{}, err
+	}
+	return framework.GetWorkloadEndpoint(client, workload)
+}
+
+func (f *rancherFixtures) createNetworkPolicy(np *networkingv1.NetworkPolicy) error {
+	return f.server.CreateNetworkPolicy(np)
+}
+
+func (f *rancherFixtures) waitForEndpoints(endpoints []framework.Endpoint, timeout time.Duration) error {
+	return f.server.WaitForEndpointsReady(endpoints, timeout)
+}
+
+func (f *rancherFixtures) resolveEndpoints(endpoints []framework.Endpoint) ([]framework.Endpoint, error) {
+	return f.server.ResolveEndpoints(endpoints)
+}
+
+func (f *rancherFixtures) listNodes() ([]string, error) {
+	return f.server.ListSchedulableNodes()
+}
+
+// cleanup deletes the projects, and with them their namespaces
+// and workloads.
+func (f *rancherFixtures) cleanup() error {
+	var errs []error
+	for _, p := range f.projects {
+		if err := f.server.ManagementClient.Project.Delete(p); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting project %v: %v", p.Name, err))
+		}
+	}
+	f.projects = nil
+	return joinErrors(errs)
+}
+
+// kubeFixtures creates the fixtures directly in a Kubernetes cluster,
+// which has no projects.
+type kubeFixtures struct {
+	cluster    *framework.KubeCluster
+	namespaces []string
+}
+
+func (f *kubeFixtures) createProject(name string) (string, error) {
+	return "", nil
+}
+
+func (f *kubeFixtures) createNamespace(ns Namespace, projectID string) error {
+	if err := f.cluster.CreateNamespace(ns.Name, ns.Labels); err != nil {
+		return err
+	}
+	f.namespaces = append(f.namespaces, ns.Name)
+	return nil
+}
+
+func (f *kubeFixtures) createWorkload(namespace, projectID string, w Workload, node string) (framework.Endpoint, error) {
+	return f.cluster.CreateProbeWorkload(namespace, w.Name, w.Labels, node)
+}
+
+func (f *kubeFixtures) createNetworkPolicy(np *networkingv1.NetworkPolicy) error {
+	return f.cluster.CreateNetworkPolicy(np)
+}
+
+func (f *kubeFixtures) waitForEndpoints(endpoints []framework.Endpoint, timeout time.Duration) error {
+	return f.cluster.WaitForEndpointsReady(endpoints, timeout)
+}
+
+func (f *kubeFixtures) resolveEndpoints(endpoints []framework.Endpoint) ([]framework.Endpoint, error) {
+	return f.cluster.ResolveEndpoints(endpoints)
+}
+
+func (f *kubeFixtures) listNodes() ([]string, error) {
+	return f.cluster.ListSchedulableNodes()
+}
+
+// cleanup deletes the namespaces, and with them the workloads and
+// policies.
+func (f *kubeFixtures) cleanup() error {
+	var errs []error
+	for _, ns := range f.namespaces {
+		if err := f.cluster.DeleteNamespace(ns); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	f.namespaces = nil
+	return joinErrors(errs)
+}
+
+func joinErrors(errs []error) error {
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package scenario
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/rancher/test-network-policy/policy"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+)
+
+// Result is the outcome of running a scenario with its workloads
+// placed as in Placement, with the reachability observed through every
+// kind of address the runner probes.
+type Result struct {
+	Placement framework.Placement
+	Expected  policy.Matrix
+	Vias      []ViaResult
+}
+
+// ViaResult is the reachability observed with the destinations probed
+// through one kind of address. Inconclusive holds the probes which
+// neither succeeded nor were blocked, and so tell nothing about the
+// policies.
+type ViaResult struct {
+	Via          framework.Via
+	Observed     policy.Matrix
+	Mismatches   []policy.Mismatch
+	Inconclusive []framework.ProbeResult
+	Probes       []framework.ProbeResult
+}
+
+// Runner builds the fixtures of scenarios, pins their workloads to
+// nodes as in Placement, applies their policies, probes every pair of
+// workloads through each kind of address in Vias and compares the
+// observed reachability with the expected one.
+type Runner struct {
+	Prober    *framework.Prober
+	Timeout   time.Duration
+	Vias      []framework.Via
+	Placement framework.Placement
+
+	fixtures fixtures
+	nodes    []string
+}
+
+// NewRunner returns a Runner against the Rancher server.
+func NewRunner(rs *framework.RancherServer) *Runner {
+	return &Runner{
+		Prober:    framework.NewProber(rs),
+		Timeout:   framework.DefaultWaitTimeout,
+		Vias:      framework.Vias,
+		Placement: framework.AnyNode,
+		fixtures:  &rancherFixtures{server: rs},
+	}
+}
+
+// NewKubeRunner returns a Runner against a Kubernetes cluster without
+// Rancher. It can only run scenarios without project isolation.
+func NewKubeRunner(c *framework.KubeCluster) *Runner {
+	return &Runner{
+		Prober:    framework.NewProber(c),
+		Timeout:   framework.DefaultWaitTimeout,
+		Vias:      framework.Vias,
+		Placement: framework.AnyNode,
+		fixtures:  &kubeFixtures{cluster: c},
+	}
+}
+
+// SupportsProjects returns whether the runner creates Rancher
+// projects, which scenarios with project isolation need.
+func (r *Runner) SupportsProjects() bool {
+	_, ok := r.fixtures.(*rancherFixtures)
+	return ok
+}
+
+// SupportsPlacement returns whether the cluster has enough schedulable
+// nodes for the Placement of the runner.
+func (r *Runner) SupportsPlacement() (bool, error) {
+	if r.Placement == framework.AnyNode {
+		return true, nil
+	}
+	if r.nodes == nil {
+		nodes, err := r.fixtures.listNodes()
+		if err != nil {
+			return false, err
+		}
+		r.nodes = nodes
+	}
+	return len(r.nodes) >= r.Placement.MinNodes(), nil
+}
+
+// Run runs the scenario. Cleanup must be called afterwards to
+// delete the fixtures, whether Run succeeded or not.
+func (r *Runner) Run(s *Scenario) (*Result, error) {
+	if s.ProjectIsolation && !r.SupportsProjects() {
+		return nil, fmt.Errorf("scenario %v needs Rancher project isolation", s.Name)
+	}
+	ok, err := r.SupportsPlacement()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("placement %v needs %v schedulable nodes, found %v", r.Placement, r.Placement.MinNodes(), len(r.nodes))
+	}
+
+	projectIDs := map[string]string{}
+	var endpoints []framework.Endpoint
+	var pinned []string
+
+	for _, p := range s.Projects {
+		projectID, err := r.fixtures.createProject(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		projectIDs[p.Name] = projectID
+
+		for _, ns := range p.Namespaces {
+			if err := r.fixtures.createNamespace(ns, projectID); err != nil {
+				return nil, err
+			}
+			for _, w := range ns.Workloads {
+				node := r.Placement.Node(r.nodes, len(endpoints))
+				e, err := r.fixtures.createWorkload(ns.Name, projectID, w, node)
+				if err != nil {
+					return nil, err
+				}
+				endpoints = append(endpoints, e)
+				pinned = append(pinned, node)
+			}
+		}
+	}
+
+	if err := r.fixtures.waitForEndpoints(endpoints, r.Timeout); err != nil {
+		return nil, err
+	}
+	endpoints, err = r.fixtures.resolveEndpoints(endpoints)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range endpoints {
+		if pinned[i] != "" && e.Node != pinned[i] {
+			return nil, fmt.Errorf("pod %v/%v runs on node %v instead of %v", e.Namespace, e.Pod, e.Node, pinned[i])
+		}
+	}
+
+	start := time.Now()
+	for i := range s.Policies {
+		if err := r.fixtures.createNetworkPolicy(&s.Policies[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	expected, err := s.ExpectedMatrix(projectIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error computing expected reachability: %v", err)
+	}
+
+	// Only the pairs placed as in Placement are expected and probed,
+	// so that the results of a placement hold none of the others.
+	byKey := map[string]framework.Endpoint{}
+	for _, e := range endpoints {
+		byKey[e.String()] = e
+	}
+	placed := policy.Matrix{}
+	var pairs []framework.Pair
+	for _, src := range expected.Keys() {
+		for _, dst := range expected.Keys() {
+			pair := framework.Pair{From: byKey[src], To: byKey[dst]}
+			if reachable, ok := expected.Get(src, dst); ok && r.Placement.Includes(pair) {
+				placed.Set(src, dst, reachable)
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	expected = placed
+
+	var expectations []framework.Expectation
+	for _, via := range r.Vias {
+		for _, pair := range pairs {
+			reachable, _ := expected.Get(pair.From.String(), pair.To.String())
+			pair.Via = via
+			expectations = append(expectations, framework.Expectation{Pair: pair, Reachable: reachable})
+		}
+	}
+	r.Prober.WaitForConvergence(start, expectations, framework.DefaultConvergenceInterval, r.Timeout)
+
+	result := &Result{Placement: r.Placement, Expected: expected}
+	for _, via := range r.Vias {
+		viaPairs := make([]framework.Pair, len(pairs))
+		for i, pair := range pairs {
+			pair.Via = via
+			viaPairs[i] = pair
+		}
+		observed, probes := r.Prober.ProbePairs(viaPairs)
+		result.Vias = append(result.Vias, ViaResult{
+			Via:          via,
+			Observed:     observed,
+			Mismatches:   expected.Diff(observed),
+			Inconclusive: framework.Inconclusive(probes),
+			Probes:       probes,
+		})
+	}
+	return result, nil
+}
+
+// Projects returns the Rancher projects created by the runner.
+func (r *Runner) Projects() []*rmgmtv3.Project {
+	if f, ok := r.fixtures.(*rancherFixtures); ok {
+		return f.projects
+	}
+	return nil
+}
+
+// Cleanup deletes the fixtures created by the runner.
+func (r *Runner) Cleanup() error {
+	return r.fixtures.cleanup()
+}
@@ -0,0 +1,293 @@
+package scenario
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/rancher/test-network-policy/policy"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// Scenario is a declarative connectivity test case: the projects,
+// namespaces and workloads to create, the NetworkPolicies to apply,
+// and the reachability expected between the workloads.
+type Scenario struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// ProjectIsolation tells whether the cluster isolates projects,
+	// in which case the policies Rancher generates in every project
+	// namespace are taken into account for the expected reachability.
+	ProjectIsolation bool `json:"projectIsolation,omitempty"`
+
+	Projects []Project                    `json:"projects"`
+	Policies []networkingv1.NetworkPolicy `json:"policies,omitempty"`
+
+	// Expected overrides the reachability computed from the
+	// policies for the listed pairs.
+	Expected []Expectation `json:"expected,omitempty"`
+
+	// File is the file the scenario was loaded from.
+	File string `json:"-"`
+}
+
+// Project is a Rancher project and its namespaces.
+type Project struct {
+	Name       string      `json:"name"`
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+// Namespace is a namespace and the workloads running in it.
+type Namespace struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Workloads []Workload        `json:"workloads"`
+}
+
+// Workload is a single pod probe workload.
+type Workload struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Expectation is the expected reachability between two workloads,
+// both referred to as <namespace>/<workload>.
+type Expectation struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Reachable bool   `json:"reachable"`
+}
+
+// LoadFile reads and validates the scenario in the YAML file.
+func LoadFile(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scenario{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("error parsing scenario %v: %v", path, err)
+	}
+	s.File = path
+
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %v: %v", path, err)
+	}
+	if err := s.CheckPortable(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %v: %v", path, err)
+	}
+	return s, nil
+}
+
+// LoadDir reads all the *.yaml scenarios in the directory, sorted
+// by file name.
+func LoadDir(dir string) ([]*Scenario, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var scenarios []*Scenario
+	for _, f := range files {
+		s, err := LoadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, s)
+	}
+	return scenarios, nil
+}
+
+// Validate checks that names are set and unique, and that the
+// policies and expectations only refer to namespaces and workloads
+// of the scenario.
+func (s *Scenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(s.Projects) == 0 {
+		return fmt.Errorf("at least one project is required")
+	}
+
+	projects := map[string]bool{}
+	namespaces := map[string]bool{}
+	workloads := map[string]bool{}
+	for _, p := range s.Projects {
+		if p.Name == "" {
+			return fmt.Errorf("project name is required")
+		}
+		if projects[p.Name] {
+			return fmt.Errorf("duplicate project %v", p.Name)
+		}
+		projects[p.Name] = true
+
+		for _, ns := range p.Namespaces {
+			if ns.Name == "" {
+				return fmt.Errorf("namespace name is required in project %v", p.Name)
+			}
+			if namespaces[ns.Name] {
+				return fmt.Errorf("duplicate namespace %v", ns.Name)
+			}
+			namespaces[ns.Name] = true
+
+			for _, w := range ns.Workloads {
+				if w.Name == "" {
+					return fmt.Errorf("workload name is required in namespace %v", ns.Name)
+				}
+				key := ns.Name + "/" + w.Name
+				if workloads[key] {
+					return fmt.Errorf("duplicate workload %v", key)
+				}
+				workloads[key] = true
+			}
+		}
+	}
+
+	for _, np := range s.Policies {
+		if np.Name == "" {
+			return fmt.Errorf("policy name is required")
+		}
+		if !namespaces[np.Namespace] {
+			return fmt.Errorf("policy %v refers to unknown namespace %q", np.Name, np.Namespace)
+		}
+	}
+
+	for _, e := range s.Expected {
+		for _, key := range []string{e.From, e.To} {
+			if !workloads[key] {
+				return fmt.Errorf("expectation refers to unknown workload %q, expected <namespace>/<workload>", key)
+			}
+		}
+		if e.From == e.To {
+			return fmt.Errorf("expectation from %v to itself", e.From)
+		}
+	}
+	return nil
+}
+
+// ExpectedMatrix computes the reachability between all the workloads
+// of the scenario from its policies, given the IDs of the projects
+// created for it, then applies the explicit expectations.
+func (s *Scenario) ExpectedMatrix(projectIDs map[string]string) (policy.Matrix, error) {
+	var namespaces []policy.Namespace
+	var pods []policy.Pod
+	policies := append([]networkingv1.NetworkPolicy(nil), s.Policies...)
+
+	for _, p := range s.Projects {
+		for _, ns := range p.Namespaces {
+			namespaces = append(namespaces, policy.ProjectNamespace(ns.Name, projectIDs[p.Name], ns.Labels))
+			if s.ProjectIsolation {
+				policies = append(policies, policy.ProjectIsolationPolicy(ns.Name, projectIDs[p.Name]))
+			}
+			for _, w := range ns.Workloads {
+				pods = append(pods, policy.Pod{Namespace: ns.Name, Name: w.Name, Labels: w.Labels})
+			}
+		}
+	}
+
+	m, err := policy.NewEvaluator(namespaces, policies).Matrix(pods, policy.DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range s.Expected {
+		m.Set(e.From, e.To, e.Reachable)
+	}
+	return m, nil
+}
+
+// CheckPortable checks that the policies of a scenario without project
+// isolation give the same reachability whether or not the cluster
+// isolates projects, and without Rancher, as it runs against all of
+// them. The explicit expectations are not taken into account.
+func (s *Scenario) CheckPortable() error {
+	if s.ProjectIsolation {
+		return nil
+	}
+
+	projectIDs := map[string]string{}
+	for _, p := range s.Projects {
+		projectIDs[p.Name] = "c-check:p-" + p.Name
+	}
+	computed := *s
+	computed.Expected = nil
+	m, err := computed.ExpectedMatrix(projectIDs)
+	if err != nil {
+		return err
+	}
+
+	isolated := computed
+	isolated.ProjectIsolation = true
+	im, err := isolated.ExpectedMatrix(projectIDs)
+	if err != nil {
+		return err
+	}
+	if diff := m.Diff(im); len(diff) != 0 {
+		return fmt.Errorf("reachability differs when projects are isolated: %v", diff)
+	}
+
+	km, err := computed.ExpectedMatrix(nil)
+	if err != nil {
+		return err
+	}
+	if diff := m.Diff(km); len(diff) != 0 {
+		return fmt.Errorf("reachability differs without projects: %v", diff)
+	}
+	return nil
+}
+
+// WithSuffix returns a copy of the scenario whose projects and
+// namespaces are named with the suffix, so that it can run alongside
+// other copies of itself, such as on the nodes of a parallel run.
+func (s *Scenario) WithSuffix(suffix string) *Scenario {
+	c := *s
+	c.Projects = make([]Project, len(s.Projects))
+	for i, p := range s.Projects {
+		p.Name += suffix
+		namespaces := make([]Namespace, len(p.Namespaces))
+		for j, ns := range p.Namespaces {
+			ns.Name += suffix
+			namespaces[j] = ns
+		}
+		p.Namespaces = namespaces
+		c.Projects[i] = p
+	}
+
+	c.Policies = make([]networkingv1.NetworkPolicy, len(s.Policies))
+	for i, np := range s.Policies {
+		np.Namespace += suffix
+		c.Policies[i] = np
+	}
+
+	c.Expected = make([]Expectation, len(s.Expected))
+	for i, e := range s.Expected {
+		c.Expected[i] = Expectation{
+			From:      withNamespaceSuffix(e.From, suffix),
+			To:        withNamespaceSuffix(e.To, suffix),
+			Reachable: e.Reachable,
+		}
+	}
+	return &c
+}
+
+// withNamespaceSuffix adds the suffix to the namespace of the
+// <namespace>/<workload> key.
+func withNamespaceSuffix(key, suffix string) string {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return key
+	}
+	return parts[0] + suffix + "/" + parts[1]
+}
+
+func (s *Scenario) String() string {
+	if s.Description == "" {
+		return s.Name
+	}
+	return s.Name + ": " + strings.TrimSpace(s.Description)
+}
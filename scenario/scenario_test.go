@@ -0,0 +1,178 @@
+package scenario
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+const scenariosDir = "../suites/networkpolicy/scenarios"
+
+func TestLoadSuiteScenarios(t *testing.T) {
+	scenarios, err := LoadDir(scenariosDir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatalf("no scenarios found in %v", scenariosDir)
+	}
+
+	for _, s := range scenarios {
+		projectIDs := map[string]string{}
+		for _, p := range s.Projects {
+			projectIDs[p.Name] = "c-test:p-" + p.Name
+		}
+
+		// Compute the matrix without the explicit expectations, which
+		// are expected to agree with the policies.
+		computed := *s
+		computed.Expected = nil
+		m, err := computed.ExpectedMatrix(projectIDs)
+		if err != nil {
+			t.Errorf("%v: err: %v", s.Name, err)
+			continue
+		}
+		for _, e := range s.Expected {
+			if allowed, _ := m.Get(e.From, e.To); allowed != e.Reachable {
+				t.Errorf("%v: expectation %v -> %v reachable=%v disagrees with the policies", s.Name, e.From, e.To, e.Reachable)
+			}
+		}
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	scenarios, err := LoadDir(scenariosDir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, s := range scenarios {
+		before := s.String() + s.Projects[0].Namespaces[0].Name
+		suffixed := s.WithSuffix("-n2")
+		if err := suffixed.Validate(); err != nil {
+			t.Errorf("%v: err: %v", s.Name, err)
+			continue
+		}
+		if after := s.String() + s.Projects[0].Namespaces[0].Name; after != before {
+			t.Errorf("%v: WithSuffix modified the scenario", s.Name)
+		}
+
+		projectIDs := map[string]string{}
+		suffixedIDs := map[string]string{}
+		for _, p := range s.Projects {
+			projectIDs[p.Name] = "c-test:p-" + p.Name
+			suffixedIDs[p.Name+"-n2"] = "c-test:p-" + p.Name
+		}
+		m, err := s.ExpectedMatrix(projectIDs)
+		if err != nil {
+			t.Fatalf("%v: err: %v", s.Name, err)
+		}
+		sm, err := suffixed.ExpectedMatrix(suffixedIDs)
+		if err != nil {
+			t.Fatalf("%v: err: %v", s.Name, err)
+		}
+		for _, src := range m.Keys() {
+			for _, dst := range m.Keys() {
+				want, ok := m.Get(src, dst)
+				if !ok {
+					continue
+				}
+				got, ok := sm.Get(withNamespaceSuffix(src, "-n2"), withNamespaceSuffix(dst, "-n2"))
+				if !ok || got != want {
+					t.Errorf("%v: %v -> %v reachable=%v with suffix, want %v", s.Name, src, dst, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCheckPortable(t *testing.T) {
+	s := &Scenario{}
+	err := yaml.Unmarshal([]byte(`
+name: s
+projects:
+- name: p1
+  namespaces:
+  - name: ns1
+    workloads:
+    - name: w1
+- name: p2
+  namespaces:
+  - name: ns2
+    workloads:
+    - name: w2
+policies:
+- metadata:
+    name: deny-all
+    namespace: ns1
+  spec:
+    podSelector: {}
+`), s)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// ns1 -> ns2 is only denied when the projects are isolated.
+	if err := s.CheckPortable(); err == nil || !strings.Contains(err.Error(), "projects are isolated") {
+		t.Errorf("expected the scenario not to be portable, got %v", err)
+	}
+
+	s.ProjectIsolation = true
+	if err := s.CheckPortable(); err != nil {
+		t.Errorf("err: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]string{
+		"name is required": `
+projects:
+- name: p
+`,
+		"duplicate namespace": `
+name: s
+projects:
+- name: p1
+  namespaces:
+  - name: ns
+- name: p2
+  namespaces:
+  - name: ns
+`,
+		"unknown namespace": `
+name: s
+projects:
+- name: p
+  namespaces:
+  - name: ns
+policies:
+- metadata:
+    name: np
+    namespace: other
+`,
+		"unknown workload": `
+name: s
+projects:
+- name: p
+  namespaces:
+  - name: ns
+    workloads:
+    - name: w
+expected:
+- from: ns/w
+  to: w
+`,
+	}
+
+	for expected, data := range tests {
+		s := &Scenario{}
+		if err := yaml.Unmarshal([]byte(data), s); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		err := s.Validate()
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q, got %v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package networkpolicy_test
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/test-network-policy/framework"
+	rclusterv3 "github.com/rancher/types/client/cluster/v3"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+)
+
+// DefaultChurnCycles is the number of create and delete cycles of the
+// churn spec, unless RANCHER_TEST_CHURN_CYCLES is set.
+const DefaultChurnCycles = 5
+
+// churnCycles returns the number of cycles of the churn spec.
+func churnCycles() int {
+	v := os.Getenv("RANCHER_TEST_CHURN_CYCLES")
+	if v == "" {
+		return DefaultChurnCycles
+	}
+	n, err := strconv.Atoi(v)
+	Expect(err).NotTo(HaveOccurred(), "invalid RANCHER_TEST_CHURN_CYCLES")
+	Expect(n).To(BeNumerically(">", 0), "invalid RANCHER_TEST_CHURN_CYCLES")
+	return n
+}
+
+var _ = Describe("Churn", func() {
+	var (
+		control   *framework.Topology
+		churned   []*rmgmtv3.Project
+		survivors []*rclusterv3.Namespace
+	)
+
+	BeforeEach(func() {
+		requireRancher()
+
+		churned, survivors = nil, nil
+		var err error
+		control, err = framework.BuildTopology(RancherServer, framework.TopologySpec{
+			nodeName("proj-churn-alpha"): {
+				nodeName("ns1-in-proj-churn-alpha"): {"w1"},
+				nodeName("ns2-in-proj-churn-alpha"): {"w2"},
+			},
+			nodeName("proj-churn-bravo"): {
+				nodeName("ns1-in-proj-churn-bravo"): {"w3"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred(), "while building the control topology")
+	})
+
+	AfterEach(func() {
+		if RancherServer == nil {
+			return
+		}
+		if control != nil {
+			collectDiagnosticsOnFailure(control.Project(nodeName("proj-churn-alpha")), control.Project(nodeName("proj-churn-bravo")))
+		}
+
+		By("deleting the projects", func() {
+			// The projects of the cycles are already deleted unless the
+			// spec failed halfway through, so errors are only printed.
+			for _, p := range churned {
+				if err := RancherServer.ManagementClient.Project.Delete(p); err != nil {
+					fmt.Fprintf(GinkgoWriter, "%v\n", err)
+				}
+			}
+			if control != nil {
+				Expect(control.Delete()).To(Succeed(), "while deleting the control topology")
+				control = nil
+			}
+		})
+	})
+
+	It("should keep isolation and leave no orphaned NetworkPolicies while projects are created and deleted", func() {
+		prober := framework.NewProber(RancherServer)
+		timeout := time.Duration(DefaultTimeout) * time.Second
+
+		endpoints, err := control.Endpoints()
+		Expect(err).NotTo(HaveOccurred(), "while fetching the control endpoints")
+		w1, w2, w3 := endpoints[0], endpoints[1], endpoints[2]
+
+		background := prober.StartBackground([]framework.Expectation{
+			{Pair: framework.Pair{From: w1, To: w2}, Reachable: true},
+			{Pair: framework.Pair{From: w3, To: w1}, Reachable: false},
+			{Pair: framework.Pair{From: w1, To: w3}, Reachable: false},
+		}, framework.DefaultConvergenceInterval)
+		defer background.Stop()
+
+		By("creating and deleting projects and namespaces", func() {
+			for i := 1; i <= churnCycles(); i++ {
+				projA, err := RancherServer.CreateProject(nodeName(fmt.Sprintf("proj-churn-%d-a", i)))
+				if projA != nil {
+					churned = append(churned, projA)
+				}
+				Expect(err).NotTo(HaveOccurred(), "while creating project a of cycle %d", i)
+				projB, err := RancherServer.CreateProject(nodeName(fmt.Sprintf("proj-churn-%d-b", i)))
+				if projB != nil {
+					churned = append(churned, projB)
+				}
+				Expect(err).NotTo(HaveOccurred(), "while creating project b of cycle %d", i)
+
+				ns1, err := RancherServer.CreateNamespace(nodeName(fmt.Sprintf("ns1-churn-%d", i)), projA.ID, nil)
+				Expect(err).NotTo(HaveOccurred(), "while creating namespace ns1 of cycle %d", i)
+				ns2, err := RancherServer.CreateNamespace(nodeName(fmt.Sprintf("ns2-churn-%d", i)), projA.ID, nil)
+				Expect(err).NotTo(HaveOccurred(), "while creating namespace ns2 of cycle %d", i)
+				ns3, err := RancherServer.CreateNamespace(nodeName(fmt.Sprintf("ns3-churn-%d", i)), projA.ID, nil)
+				if ns3 != nil {
+					survivors = append(survivors, ns3)
+				}
+				Expect(err).NotTo(HaveOccurred(), "while creating namespace ns3 of cycle %d", i)
+
+				Expect(RancherServer.MoveNamespace(ns2, projB.ID)).To(Succeed(), "while moving namespace ns2 of cycle %d", i)
+				Expect(RancherServer.DefaultClusterClient.Namespace.Delete(ns1)).To(Succeed(), "while deleting namespace ns1 of cycle %d", i)
+
+				// Deleting the projects deletes their namespaces, ns2
+				// along with project b. ns3 is moved out of project a
+				// first, so that it survives it and Rancher has to
+				// remove the policies of project a from it.
+				Expect(RancherServer.MoveNamespace(ns3, "")).To(Succeed(), "while moving namespace ns3 of cycle %d out of its project", i)
+				Expect(RancherServer.ManagementClient.Project.Delete(projA)).To(Succeed(), "while deleting project a of cycle %d", i)
+				Expect(RancherServer.ManagementClient.Project.Delete(projB)).To(Succeed(), "while deleting project b of cycle %d", i)
+				churned = churned[:len(churned)-2]
+			}
+		})
+
+		violations := background.Stop()
+		Expect(background.Rounds()).To(BeNumerically(">", 0), "expected background probes to run")
+		Expect(violations).To(BeEmpty(), "the control pairs were disrupted by the churn")
+
+		By("checking that no orphaned NetworkPolicies remain in any namespace", func() {
+			orphans, err := RancherServer.WaitForNoOrphanedPolicies(timeout)
+			Expect(err).NotTo(HaveOccurred(), "while listing the network policies")
+			Expect(orphans).To(BeEmpty(), "orphaned Rancher generated NetworkPolicies remain")
+		})
+	})
+})
@@ -1,31 +1,197 @@
 package networkpolicy_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
 	. "github.com/onsi/gomega"
 	"github.com/rancher/test-network-policy/framework"
-	"github.com/onsi/ginkgo/config"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
 )
 
 var RancherServer *framework.RancherServer
 
+// KubeCluster is set instead of RancherServer when the suite runs
+// directly against the cluster of RANCHER_TEST_KUBECONFIG, in which
+// case the specs needing Rancher are skipped.
+var KubeCluster *framework.KubeCluster
+
+// Transcripts records the commands run in pods by the current spec.
+var Transcripts = &framework.TranscriptRecorder{}
+
+// ConvergenceRecorder collects the convergence samples of all specs
+// so that their percentiles can be reported at the end of the suite.
+var ConvergenceRecorder = &framework.ConvergenceRecorder{}
+
+// ConnectivityRecorder collects the connectivity observed by each
+// spec for the JSON report.
+var ConnectivityRecorder = &framework.ConnectivityRecorder{}
+
+// ArtifactCollector captures the state of the projects of failed
+// specs. It is nil unless an artifact directory is configured.
+var ArtifactCollector *framework.ArtifactCollector
+
+var artifactDir = os.Getenv("RANCHER_TEST_ARTIFACT_DIR")
+
 func TestNetworkpolicy(t *testing.T) {
 	RegisterFailHandler(Fail)
 	config.DefaultReporterConfig.SlowSpecThreshold = 60
 	config.DefaultReporterConfig.Verbose = true
-	RunSpecs(t, "Networkpolicy Suite")
+
+	var specReporters []Reporter
+	if reportDir := os.Getenv("RANCHER_TEST_REPORT_DIR"); reportDir != "" {
+		if err := os.MkdirAll(reportDir, 0755); err != nil {
+			t.Fatalf("error creating report directory: %v", err)
+		}
+		for _, r := range framework.NewFileReporters(reportDir, ConnectivityRecorder, ConvergenceRecorder, ScaleRecorder) {
+			specReporters = append(specReporters, r)
+		}
+		if artifactDir == "" {
+			artifactDir = filepath.Join(reportDir, "artifacts")
+		}
+	}
+	RunSpecsWithDefaultAndCustomReporters(t, "Networkpolicy Suite", specReporters)
 }
 
-var _ = BeforeSuite(func() {
-	//logrus.Infof("BeforeSuite")
+// The first node connects to the Rancher server, minting a token when
+// it logs in with a password, and shares the connection with the other
+// nodes of a parallel run.
+var _ = SynchronizedBeforeSuite(func() []byte {
+	if os.Getenv("RANCHER_TEST_KUBECONFIG") != "" {
+		return nil
+	}
+	rs, err := framework.NewRancherServerFromEnvVars()
+	Expect(err).NotTo(HaveOccurred(), "while creating rancher server")
+	RancherServer = rs
+
+	data, err := json.Marshal(rs.Config())
+	Expect(err).NotTo(HaveOccurred(), "while marshalling the rancher server config")
+	return data
+}, func(data []byte) {
 	var err error
 
-	RancherServer, err = framework.NewRancherServerFromEnvVars()
-	Expect(err).NotTo(HaveOccurred(), "while creating rancher server")
+	if os.Getenv("RANCHER_TEST_KUBECONFIG") != "" {
+		KubeCluster, err = framework.NewKubeClusterFromEnvVars()
+		Expect(err).NotTo(HaveOccurred(), "while loading kubeconfig")
+		KubeCluster.Transcripts = Transcripts
+		return
+	}
+
+	if RancherServer == nil {
+		serverConfig := framework.ServerConfig{}
+		Expect(json.Unmarshal(data, &serverConfig)).To(Succeed(), "while unmarshalling the rancher server config")
+		RancherServer, err = framework.NewRancherServerFromConfig(serverConfig)
+		Expect(err).NotTo(HaveOccurred(), "while creating rancher server")
+	}
+
+	RancherServer.Transcripts = Transcripts
+	if artifactDir != "" {
+		ArtifactCollector = framework.NewArtifactCollector(RancherServer, artifactDir)
+	}
+})
+
+var _ = BeforeEach(func() {
+	Transcripts.Reset()
 })
 
-var _ = AfterSuite(func() {
-	//logrus.Infof("AfterSuite")
+// The token shared by the nodes is deleted once they are all done.
+var _ = SynchronizedAfterSuite(func() {
+	ConvergenceRecorder.WriteSummary(GinkgoWriter)
+	ScaleRecorder.WriteSummary(GinkgoWriter)
+	if topology != nil {
+		Expect(topology.Delete()).To(Succeed(), "while deleting the shared topology")
+	}
+}, func() {
+	if RancherServer != nil {
+		Expect(RancherServer.Close()).To(Succeed(), "while deleting the token of the run")
+	}
 })
+
+// nodeSuffix returns the suffix of the names of the fixtures created by
+// this node of a parallel run, so that they do not collide with the
+// fixtures of the other nodes. It is empty when not running in parallel.
+func nodeSuffix() string {
+	if config.GinkgoConfig.ParallelTotal <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("-n%d", config.GinkgoConfig.ParallelNode)
+}
+
+// nodeName returns the name with the suffix of this node.
+func nodeName(name string) string {
+	return name + nodeSuffix()
+}
+
+// topology is shared by the specs needing two isolated projects. It is
+// built by the first of them, and built again after a spec fails or
+// leaves it unhealthy.
+var topology *framework.Topology
+
+// mutatesMarker marks the specs which change the shared topology, which
+// is then reset after them.
+const mutatesMarker = "[Mutates]"
+
+// mutates returns whether the current spec changes the shared topology.
+func mutates() bool {
+	return strings.Contains(CurrentGinkgoTestDescription().FullTestText, mutatesMarker)
+}
+
+// deleteTopology deletes the shared topology, so that the next spec
+// builds it again.
+func deleteTopology() {
+	if err := topology.Delete(); err != nil {
+		fmt.Fprintf(GinkgoWriter, "%v\n", err)
+	}
+	topology = nil
+}
+
+// requireRancher skips the current spec unless the suite runs against
+// a Rancher server.
+func requireRancher() {
+	if RancherServer == nil {
+		Skip("needs a Rancher server")
+	}
+}
+
+// collectDiagnosticsOnFailure prints how the NetworkPolicies of the
+// projects differ from the ones Rancher is expected to generate, and
+// captures the state of the projects when an artifact directory is
+// configured, if the current spec failed. It must run before the
+// projects are deleted.
+func collectDiagnosticsOnFailure(projects ...*rmgmtv3.Project) {
+	description := CurrentGinkgoTestDescription()
+	if !description.Failed {
+		return
+	}
+
+	var projectIDs []string
+	for _, p := range projects {
+		if p != nil {
+			projectIDs = append(projectIDs, p.ID)
+		}
+	}
+	if len(projectIDs) == 0 {
+		return
+	}
+
+	if report, err := RancherServer.ProjectPolicyReport(projectIDs); err != nil {
+		fmt.Fprintf(GinkgoWriter, "%v\n", err)
+	} else {
+		fmt.Fprintf(GinkgoWriter, "%v", report)
+	}
+
+	if ArtifactCollector == nil {
+		return
+	}
+	dir, err := ArtifactCollector.Collect(description.FullTestText, projectIDs, Transcripts.Transcripts())
+	if err != nil {
+		fmt.Fprintf(GinkgoWriter, "%v\n", err)
+	}
+	fmt.Fprintf(GinkgoWriter, "Artifacts of the failed spec written to %v\n", dir)
+}
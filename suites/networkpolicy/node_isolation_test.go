@@ -0,0 +1,100 @@
+package networkpolicy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/rancher/test-network-policy/policy"
+	rclusterv3 "github.com/rancher/types/client/cluster/v3"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+var _ = Describe("NodeIsolation", func() {
+	var (
+		projAlpha, projBravo *rmgmtv3.Project
+		namespaces           []*rclusterv3.Namespace
+		endpoints            []framework.Endpoint
+	)
+
+	BeforeEach(func() {
+		requireRancher()
+
+		nodes, err := RancherServer.ListSchedulableNodes()
+		Expect(err).NotTo(HaveOccurred(), "while listing the schedulable nodes")
+		Expect(nodes).NotTo(BeEmpty(), "no schedulable node")
+
+		projAlpha, projBravo = nil, nil
+		namespaces, endpoints = nil, nil
+		for _, p := range []struct {
+			project **rmgmtv3.Project
+			name    string
+		}{
+			{&projAlpha, "alpha"},
+			{&projBravo, "bravo"},
+		} {
+			*p.project, err = RancherServer.CreateProject(nodeName("proj-nodes-" + p.name))
+			Expect(err).NotTo(HaveOccurred(), "while creating project %v", p.name)
+
+			ns, err := RancherServer.CreateNamespace(nodeName("ns-in-proj-nodes-"+p.name), (*p.project).ID, nil)
+			Expect(err).NotTo(HaveOccurred(), "while creating namespace in project %v", p.name)
+			namespaces = append(namespaces, ns)
+
+			client, err := RancherServer.GetProjectClientByID((*p.project).ID)
+			Expect(err).NotTo(HaveOccurred(), "while creating client for project %v", p.name)
+			_, err = framework.CreateDaemonSet(client, framework.NewProbeDaemonSet("ds-"+p.name, ns.Name))
+			Expect(err).NotTo(HaveOccurred(), "while creating daemon set in %v", ns.Name)
+
+			e, err := RancherServer.WaitForDaemonSetEndpoints(ns.Name, "ds-"+p.name, nodes, framework.DefaultWaitTimeout)
+			Expect(err).NotTo(HaveOccurred(), "while waiting for the daemon set in %v", ns.Name)
+			for i := range e {
+				e[i].Project = (*p.project).ID
+			}
+			endpoints = append(endpoints, e...)
+		}
+	})
+
+	AfterEach(func() {
+		if RancherServer == nil {
+			return
+		}
+		collectDiagnosticsOnFailure(projAlpha, projBravo)
+
+		By("deleting projects", func() {
+			for _, p := range []*rmgmtv3.Project{projAlpha, projBravo} {
+				if p != nil {
+					Expect(RancherServer.ManagementClient.Project.Delete(p)).To(Succeed(), "while deleting project %v", p.Name)
+				}
+			}
+		})
+	})
+
+	It("projects should be isolated on every node", func() {
+		var nsList []policy.Namespace
+		var policies []networkingv1.NetworkPolicy
+		for _, n := range namespaces {
+			ns, err := RancherServer.DefaultClusterClient.Namespace.ByID(n.ID)
+			Expect(err).NotTo(HaveOccurred(), "while fetching namespace %v", n.Name)
+			nsList = append(nsList, policy.Namespace{Name: ns.Name, Labels: ns.Labels})
+			policies = append(policies, policy.ProjectIsolationPolicy(ns.Name, ns.ProjectID))
+		}
+
+		var pods []policy.Pod
+		for _, e := range endpoints {
+			pods = append(pods, e.PolicyPod())
+		}
+		expected, err := policy.NewEvaluator(nsList, policies).Matrix(pods, policy.DefaultPort)
+		Expect(err).NotTo(HaveOccurred(), "while computing the expected matrix")
+
+		// Probe the pod IPs, since the services of the daemon sets
+		// would pick any of their pods.
+		prober := framework.NewProber(RancherServer)
+		observed, results := prober.ProbeMatrixVia(endpoints, framework.ViaPodIP)
+		ConnectivityRecorder.Record(CurrentGinkgoTestDescription().FullTestText,
+			framework.NewConnectivity("project isolation across nodes", expected, results))
+
+		anomalies := framework.NodeAnomalies(expected, results)
+		Expect(anomalies).To(BeEmpty(), "probes failed on nodes:\n%v\nexpected:\n%v\nobserved:\n%v",
+			framework.FormatNodeAnomalies(anomalies), expected, observed)
+	})
+})
@@ -1,15 +1,19 @@
 package networkpolicy_test
 
 import (
+	"fmt"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	normantypes "github.com/rancher/norman/types"
-	"github.com/rancher/test-network-policy/utils"
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/rancher/test-network-policy/policy"
 	rclusterv3 "github.com/rancher/types/client/cluster/v3"
 	rmgmtv3 "github.com/rancher/types/client/management/v3"
 	rprojectv3 "github.com/rancher/types/client/project/v3"
 	//"github.com/sirupsen/logrus"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	networkingv1 "k8s.io/api/networking/v1"
 )
 
 var DefaultTimeout = 60
@@ -26,225 +30,68 @@ var _ = Describe("ProjectIsolation", func() {
 	)
 
 	BeforeEach(func() {
-		By("creating two different projects", func() {
-			projAlpha, err = RancherServer.ManagementClient.Project.Create(&rmgmtv3.Project{
-				Name:      "proj-alpha",
-				ClusterId: RancherServer.DefaultCluster.ID,
-			})
-			Expect(err).NotTo(HaveOccurred(), "while creating project alpha")
-
-			projBravo, err = RancherServer.ManagementClient.Project.Create(&rmgmtv3.Project{
-				Name:      "proj-bravo",
-				ClusterId: RancherServer.DefaultCluster.ID,
-			})
-			Expect(err).NotTo(HaveOccurred(), "while creating project bravo")
-
-			Eventually(func() string {
-				if p, err := RancherServer.ManagementClient.Project.ByID(projAlpha.ID); err == nil {
-					return p.State
-				}
-				return ""
-			}, DefaultTimeout).Should(Equal("active"), "waiting for project alpha to be active")
-
-			Eventually(func() string {
-				if p, err := RancherServer.ManagementClient.Project.ByID(projBravo.ID); err == nil {
-					return p.State
-				}
-				return ""
-			}, DefaultTimeout).Should(Equal("active"), "waiting for project bravo to be active")
+		requireRancher()
 
-			projAlphaClient, err = RancherServer.GetProjectClientByID(projAlpha.ID)
-			Expect(err).NotTo(HaveOccurred(), "creating client for project alpha")
-
-			projBravoClient, err = RancherServer.GetProjectClientByID(projBravo.ID)
-			Expect(err).NotTo(HaveOccurred(), "creating client for project bravo")
-		})
-
-		By("creating namespaces in proj-alpha", func() {
-			ns1InAlpha, err = RancherServer.DefaultClusterClient.Namespace.Create(&rclusterv3.Namespace{
-				Name:      "ns1-in-proj-alpha",
-				ProjectID: projAlpha.ID,
-			})
-			Expect(err).NotTo(HaveOccurred(), "while creating namespace ns1")
-
-			ns2InAlpha, err = RancherServer.DefaultClusterClient.Namespace.Create(&rclusterv3.Namespace{
-				Name:      "ns2-in-proj-alpha",
-				ProjectID: projAlpha.ID,
-			})
-			Expect(err).NotTo(HaveOccurred(), "while creating namespace ns2 in project alpha")
-
-			Eventually(func() string {
-				if n, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns1InAlpha.ID); err == nil {
-					return n.State
-				}
-				return ""
-			}, DefaultTimeout).Should(Equal("active"), "waiting for namespace ns1-in-proj-alpha to become active")
-
-			Eventually(func() string {
-				if n, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns2InAlpha.ID); err == nil {
-					return n.State
-				}
-				return ""
-			}, DefaultTimeout).Should(Equal("active"), "waiting for namespace ns2-in-proj-alpha to become active")
-		})
-
-		By("creating namespaces in proj-bravo", func() {
-			ns1InBravo, err = RancherServer.DefaultClusterClient.Namespace.Create(&rclusterv3.Namespace{
-				Name:      "ns1-in-proj-bravo",
-				ProjectID: projBravo.ID,
-			})
-			Expect(err).NotTo(HaveOccurred(), "while creating namespace ns1")
-
-			ns2InBravo, err = RancherServer.DefaultClusterClient.Namespace.Create(&rclusterv3.Namespace{
-				Name:      "ns2-in-proj-bravo",
-				ProjectID: projBravo.ID,
-			})
-			Expect(err).NotTo(HaveOccurred(), "while creating namespace ns2 in project bravo")
-
-			Eventually(func() string {
-				if n, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns1InBravo.ID); err == nil {
-					return n.State
-				}
-				return ""
-			}, DefaultTimeout).Should(Equal("active"), "waiting for namespace ns1-in-proj-bravo to become active")
-
-			Eventually(func() string {
-				if n, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns2InBravo.ID); err == nil {
-					return n.State
-				}
-				return ""
-			}, DefaultTimeout).Should(Equal("active"), "waiting for namespace ns2-in-proj-bravo to become active")
-		})
-
-		By("creating workload in ns1-in-proj-alpha", func() {
-			w1InNS1ProjAlpha = &rprojectv3.Workload{
-				Name:        "workload-in-ns1-in-proj-alpha",
-				NamespaceId: ns1InAlpha.Name,
-				DNSPolicy:   "ClusterFirst",
-				Containers: []rprojectv3.Container{
-					{
-						Name:  "workload-in-ns1-in-proj-alpha",
-						Image: "leodotcloud/swiss-army-knife",
-						Stdin: true,
-						TTY:   true,
-					},
-				},
-				DeploymentConfig: &rprojectv3.DeploymentConfig{
-					MaxSurge:             intstr.FromInt(1),
-					Strategy:             "RollingUpdate",
-					RevisionHistoryLimit: func(i int64) *int64 { return &i }(10),
-				},
+		if topology != nil {
+			if err := topology.Verify(); err != nil {
+				fmt.Fprintf(GinkgoWriter, "Rebuilding the shared topology: %v\n", err)
+				deleteTopology()
 			}
-			w1InNS1ProjAlpha, err = projAlphaClient.Workload.Create(w1InNS1ProjAlpha)
-			Expect(err).NotTo(HaveOccurred(), "while creating workload w1InNS1ProjAlpha")
-		})
-
-		By("creating workload in ns2-in-proj-alpha", func() {
-			w2InNS2ProjAlpha = &rprojectv3.Workload{
-				Name:        "workload-in-ns2-in-proj-alpha",
-				NamespaceId: ns2InAlpha.Name,
-				DNSPolicy:   "ClusterFirst",
-				Containers: []rprojectv3.Container{
-					{
-						Name:  "workload-in-ns2-in-proj-alpha",
-						Image: "leodotcloud/swiss-army-knife",
-						Stdin: true,
-						TTY:   true,
+		}
+		if topology == nil {
+			By("building the shared topology", func() {
+				topology, err = framework.BuildTopology(RancherServer, framework.TopologySpec{
+					nodeName("proj-alpha"): {
+						nodeName("ns1-in-proj-alpha"): {"workload-in-ns1-in-proj-alpha"},
+						nodeName("ns2-in-proj-alpha"): {"workload-in-ns2-in-proj-alpha"},
 					},
-				},
-				DeploymentConfig: &rprojectv3.DeploymentConfig{
-					MaxSurge:             intstr.FromInt(1),
-					Strategy:             "RollingUpdate",
-					RevisionHistoryLimit: func(i int64) *int64 { return &i }(10),
-				},
-			}
-			w2InNS2ProjAlpha, err = projAlphaClient.Workload.Create(w2InNS2ProjAlpha)
-			Expect(err).NotTo(HaveOccurred(), "while creating workload w2InNS2ProjAlpha")
-		})
-
-		By("creating workload in ns1-in-proj-bravo", func() {
-			w3InNS1ProjBravo = &rprojectv3.Workload{
-				Name:        "workload-in-ns1-in-proj-bravo",
-				NamespaceId: ns1InBravo.Name,
-				DNSPolicy:   "ClusterFirst",
-				Containers: []rprojectv3.Container{
-					{
-						Name:  "workload-in-ns1-in-proj-bravo",
-						Image: "leodotcloud/swiss-army-knife",
-						Stdin: true,
-						TTY:   true,
+					nodeName("proj-bravo"): {
+						nodeName("ns1-in-proj-bravo"): {"workload-in-ns1-in-proj-bravo"},
+						nodeName("ns2-in-proj-bravo"): {"workload-in-ns2-in-proj-bravo"},
 					},
-				},
-				DeploymentConfig: &rprojectv3.DeploymentConfig{
-					MaxSurge:             intstr.FromInt(1),
-					Strategy:             "RollingUpdate",
-					RevisionHistoryLimit: func(i int64) *int64 { return &i }(10),
-				},
-			}
-			w3InNS1ProjBravo, err = projBravoClient.Workload.Create(w3InNS1ProjBravo)
-			Expect(err).NotTo(HaveOccurred(), "while creating workload w3InNS1ProjBravo")
-		})
-
-		By("creating workload in ns2-in-proj-bravo", func() {
-			w4InNS2ProjBravo = &rprojectv3.Workload{
-				Name:        "workload-in-ns2-in-proj-bravo",
-				NamespaceId: ns2InBravo.Name,
-				DNSPolicy:   "ClusterFirst",
-				Containers: []rprojectv3.Container{
-					{
-						Name:  "workload-in-ns2-in-proj-bravo",
-						Image: "leodotcloud/swiss-army-knife",
-						Stdin: true,
-						TTY:   true,
-					},
-				},
-				DeploymentConfig: &rprojectv3.DeploymentConfig{
-					MaxSurge:             intstr.FromInt(1),
-					Strategy:             "RollingUpdate",
-					RevisionHistoryLimit: func(i int64) *int64 { return &i }(10),
-				},
-			}
-			w4InNS2ProjBravo, err = projBravoClient.Workload.Create(w4InNS2ProjBravo)
-			Expect(err).NotTo(HaveOccurred(), "while creating workload w4InNS2ProjBravo")
-		})
-
-		Eventually(func() string {
-			if w, err := projAlphaClient.Workload.ByID(w1InNS1ProjAlpha.ID); err == nil {
-				return w.State
-			}
-			return ""
-		}, DefaultTimeout).Should(Equal("active"), "waiting for workload to become active")
-
-		Eventually(func() string {
-			if w, err := projAlphaClient.Workload.ByID(w2InNS2ProjAlpha.ID); err == nil {
-				return w.State
-			}
-			return ""
-		}, DefaultTimeout).Should(Equal("active"), "waiting for workload to become active")
-
-		Eventually(func() string {
-			if w, err := projBravoClient.Workload.ByID(w3InNS1ProjBravo.ID); err == nil {
-				return w.State
-			}
-			return ""
-		}, DefaultTimeout).Should(Equal("active"), "waiting for workload to become active")
-
-		Eventually(func() string {
-			if w, err := projBravoClient.Workload.ByID(w4InNS2ProjBravo.ID); err == nil {
-				return w.State
-			}
-			return ""
-		}, DefaultTimeout).Should(Equal("active"), "waiting for workload to become active")
-
+				})
+				if err != nil {
+					deleteTopology()
+				}
+				Expect(err).NotTo(HaveOccurred(), "while building the shared topology")
+			})
+		}
+
+		projAlpha = topology.Project(nodeName("proj-alpha"))
+		projBravo = topology.Project(nodeName("proj-bravo"))
+		projAlphaClient = topology.ProjectClient(nodeName("proj-alpha"))
+		projBravoClient = topology.ProjectClient(nodeName("proj-bravo"))
+
+		ns1InAlpha = topology.Namespace(nodeName("ns1-in-proj-alpha"))
+		ns2InAlpha = topology.Namespace(nodeName("ns2-in-proj-alpha"))
+		ns1InBravo = topology.Namespace(nodeName("ns1-in-proj-bravo"))
+		ns2InBravo = topology.Namespace(nodeName("ns2-in-proj-bravo"))
+
+		w1InNS1ProjAlpha = topology.Workload(ns1InAlpha.Name, "workload-in-ns1-in-proj-alpha")
+		w2InNS2ProjAlpha = topology.Workload(ns2InAlpha.Name, "workload-in-ns2-in-proj-alpha")
+		w3InNS1ProjBravo = topology.Workload(ns1InBravo.Name, "workload-in-ns1-in-proj-bravo")
+		w4InNS2ProjBravo = topology.Workload(ns2InBravo.Name, "workload-in-ns2-in-proj-bravo")
 	})
 
 	AfterEach(func() {
-		By("deleting projects", func() {
-			err = RancherServer.ManagementClient.Project.Delete(projAlpha)
-			Expect(err).NotTo(HaveOccurred(), "while deleting project alpha")
-			err = RancherServer.ManagementClient.Project.Delete(projBravo)
-			Expect(err).NotTo(HaveOccurred(), "while deleting project bravo")
-		})
+		if RancherServer == nil || topology == nil {
+			return
+		}
+		collectDiagnosticsOnFailure(projAlpha, projBravo)
+
+		switch {
+		case CurrentGinkgoTestDescription().Failed:
+			By("deleting the shared topology after the failure", deleteTopology)
+		case mutates():
+			By("resetting the shared topology", func() {
+				// Verify does not look at the NetworkPolicies, so a
+				// topology that could not be reset is not reused.
+				if err := topology.Reset(); err != nil {
+					deleteTopology()
+					Fail(fmt.Sprintf("while resetting the shared topology: %v", err))
+				}
+			})
+		}
 	})
 
 	It("projects should be isolated", func() {
@@ -290,41 +137,178 @@ var _ = Describe("ProjectIsolation", func() {
 		w3Pod := w3PodCollection.Data[0]
 		w4Pod := w4PodCollection.Data[0]
 
-		var output, curlCommand, wsURL string
+		var output, curlCommand string
 
 		// w2 -> w1 should succeed
 		curlCommand = "curl --max-time 5 -s http://" + w1InNS1ProjAlpha.Name + "." + w1InNS1ProjAlpha.NamespaceId
-		wsURL = utils.GetWSURL(RancherServer.URL, RancherServer.DefaultCluster.ID, w2Pod.NamespaceId, w2Pod.Name, w2Pod.Containers[0].Name, curlCommand)
-
-		output, err = utils.RunExecCommand(wsURL, RancherServer.AccessKey, RancherServer.SecretKey, RancherServer.TokenKey)
+		output, err = RancherServer.Exec(w2Pod.NamespaceId, w2Pod.Name, w2Pod.Containers[0].Name, curlCommand)
 		Expect(err).NotTo(HaveOccurred(), "while running command")
 		Expect(output).Should(ContainSubstring(w1Pod.Name))
 
 		// w4 -> w3 should succeed
 		curlCommand = "curl --max-time 5 -s http://" + w3InNS1ProjBravo.Name + "." + w3InNS1ProjBravo.NamespaceId
-		wsURL = utils.GetWSURL(RancherServer.URL, RancherServer.DefaultCluster.ID, w4Pod.NamespaceId, w4Pod.Name, w4Pod.Containers[0].Name, curlCommand)
-
-		output, err = utils.RunExecCommand(wsURL, RancherServer.AccessKey, RancherServer.SecretKey, RancherServer.TokenKey)
+		output, err = RancherServer.Exec(w4Pod.NamespaceId, w4Pod.Name, w4Pod.Containers[0].Name, curlCommand)
 		Expect(err).NotTo(HaveOccurred(), "while running command")
 		Expect(output).Should(ContainSubstring(w3Pod.Name))
 
 		// w4 -> w2 should fail
 		curlCommand = "curl --max-time 5 -s http://" + w2InNS2ProjAlpha.Name + "." + w2InNS2ProjAlpha.NamespaceId
-		wsURL = utils.GetWSURL(RancherServer.URL, RancherServer.DefaultCluster.ID, w4Pod.NamespaceId, w4Pod.Name, w4Pod.Containers[0].Name, curlCommand)
-
-		output, err = utils.RunExecCommand(wsURL, RancherServer.AccessKey, RancherServer.SecretKey, RancherServer.TokenKey)
+		output, err = RancherServer.Exec(w4Pod.NamespaceId, w4Pod.Name, w4Pod.Containers[0].Name, curlCommand)
 		Expect(err).NotTo(HaveOccurred(), "while running command")
-		Expect(output).Should(ContainSubstring("non-zero exit code"))
-		Expect(output).ShouldNot(ContainSubstring(w2Pod.Name))
+		Expect(framework.CurlOutcome(output, w2Pod.Name).Blocked()).To(BeTrue(), "expected the probe to be blocked: %v", output)
 
 		// w1 -> w3 should fail
 		curlCommand = "curl --max-time 5 -s http://" + w3InNS1ProjBravo.Name + "." + w3InNS1ProjBravo.NamespaceId
-		wsURL = utils.GetWSURL(RancherServer.URL, RancherServer.DefaultCluster.ID, w1Pod.NamespaceId, w1Pod.Name, w1Pod.Containers[0].Name, curlCommand)
-
-		output, err = utils.RunExecCommand(wsURL, RancherServer.AccessKey, RancherServer.SecretKey, RancherServer.TokenKey)
+		output, err = RancherServer.Exec(w1Pod.NamespaceId, w1Pod.Name, w1Pod.Containers[0].Name, curlCommand)
 		Expect(err).NotTo(HaveOccurred(), "while running command")
-		Expect(output).Should(ContainSubstring("non-zero exit code"))
-		Expect(output).ShouldNot(ContainSubstring(w3Pod.Name))
+		Expect(framework.CurlOutcome(output, w3Pod.Name).Blocked()).To(BeTrue(), "expected the probe to be blocked: %v", output)
+
+	})
+
+	It("should converge after moving a namespace between projects "+mutatesMarker, func() {
+		prober := framework.NewProber(RancherServer)
+		timeout := time.Duration(DefaultTimeout) * time.Second
+
+		w1, err := framework.GetWorkloadEndpoint(projAlphaClient, w1InNS1ProjAlpha)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w1")
+		w3, err := framework.GetWorkloadEndpoint(projBravoClient, w3InNS1ProjBravo)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w3")
+		w4, err := framework.GetWorkloadEndpoint(projBravoClient, w4InNS2ProjBravo)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w4")
+
+		By("moving ns2-in-proj-bravo into proj-alpha", func() {
+			ns, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns2InBravo.ID)
+			Expect(err).NotTo(HaveOccurred(), "while fetching namespace ns2-in-proj-bravo")
+
+			start := time.Now()
+			err = RancherServer.MoveNamespace(ns, projAlpha.ID)
+			Expect(err).NotTo(HaveOccurred(), "while moving namespace ns2-in-proj-bravo to project alpha")
+
+			samples := prober.WaitForConvergence(start, []framework.Expectation{
+				{Pair: framework.Pair{From: w1, To: w4}, Reachable: true},
+				{Pair: framework.Pair{From: w4, To: w1}, Reachable: true},
+				{Pair: framework.Pair{From: w3, To: w4}, Reachable: false},
+			}, framework.DefaultConvergenceInterval, timeout)
+			ConvergenceRecorder.Record(samples...)
+			for _, s := range samples {
+				Expect(s.Converged).To(BeTrue(), "%v did not converge (%v after %v)", s.Pair, s.Kind, s.Duration)
+			}
+		})
+
+		By("moving ns2-in-proj-bravo back into proj-bravo", func() {
+			ns, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns2InBravo.ID)
+			Expect(err).NotTo(HaveOccurred(), "while fetching namespace ns2-in-proj-bravo")
+
+			start := time.Now()
+			err = RancherServer.MoveNamespace(ns, projBravo.ID)
+			Expect(err).NotTo(HaveOccurred(), "while moving namespace ns2-in-proj-bravo to project bravo")
+
+			samples := prober.WaitForConvergence(start, []framework.Expectation{
+				{Pair: framework.Pair{From: w1, To: w4}, Reachable: false},
+				{Pair: framework.Pair{From: w4, To: w1}, Reachable: false},
+				{Pair: framework.Pair{From: w3, To: w4}, Reachable: true},
+			}, framework.DefaultConvergenceInterval, timeout)
+			ConvergenceRecorder.Record(samples...)
+			for _, s := range samples {
+				Expect(s.Converged).To(BeTrue(), "%v did not converge (%v after %v)", s.Pair, s.Kind, s.Duration)
+			}
+		})
+	})
+
+	It("should not disrupt established traffic while another namespace is moved "+mutatesMarker, func() {
+		prober := framework.NewProber(RancherServer)
+		timeout := time.Duration(DefaultTimeout) * time.Second
+
+		w1, err := framework.GetWorkloadEndpoint(projAlphaClient, w1InNS1ProjAlpha)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w1")
+		w2, err := framework.GetWorkloadEndpoint(projAlphaClient, w2InNS2ProjAlpha)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w2")
+		w3, err := framework.GetWorkloadEndpoint(projBravoClient, w3InNS1ProjBravo)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w3")
+		w4, err := framework.GetWorkloadEndpoint(projBravoClient, w4InNS2ProjBravo)
+		Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload w4")
+
+		background := prober.StartBackground([]framework.Expectation{
+			{Pair: framework.Pair{From: w2, To: w1}, Reachable: true},
+			{Pair: framework.Pair{From: w1, To: w2}, Reachable: true},
+			{Pair: framework.Pair{From: w3, To: w1}, Reachable: false},
+			{Pair: framework.Pair{From: w1, To: w3}, Reachable: false},
+		}, framework.DefaultConvergenceInterval)
+		defer background.Stop()
+
+		By("moving ns2-in-proj-bravo into proj-alpha and back", func() {
+			for _, projectID := range []string{projAlpha.ID, projBravo.ID} {
+				ns, err := RancherServer.DefaultClusterClient.Namespace.ByID(ns2InBravo.ID)
+				Expect(err).NotTo(HaveOccurred(), "while fetching namespace ns2-in-proj-bravo")
+
+				start := time.Now()
+				err = RancherServer.MoveNamespace(ns, projectID)
+				Expect(err).NotTo(HaveOccurred(), "while moving namespace ns2-in-proj-bravo")
+
+				samples := prober.WaitForConvergence(start, []framework.Expectation{
+					{Pair: framework.Pair{From: w1, To: w4}, Reachable: projectID == projAlpha.ID},
+				}, framework.DefaultConvergenceInterval, timeout)
+				Expect(samples[0].Converged).To(BeTrue(), "%v did not converge", samples[0].Pair)
+			}
+		})
+
+		violations := background.Stop()
+		Expect(background.Rounds()).To(BeNumerically(">", 0), "expected background probes to run")
+		Expect(violations).To(BeEmpty(), "traffic was disrupted while moving the namespace")
+	})
 
+	It("projects should be isolated as computed from the project policies", func() {
+		prober := framework.NewProber(RancherServer)
+
+		var endpoints []framework.Endpoint
+		for _, w := range []struct {
+			client   *rprojectv3.Client
+			workload *rprojectv3.Workload
+		}{
+			{projAlphaClient, w1InNS1ProjAlpha},
+			{projAlphaClient, w2InNS2ProjAlpha},
+			{projBravoClient, w3InNS1ProjBravo},
+			{projBravoClient, w4InNS2ProjBravo},
+		} {
+			e, err := framework.GetWorkloadEndpoint(w.client, w.workload)
+			Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload %v", w.workload.Name)
+			endpoints = append(endpoints, e)
+		}
+
+		var namespaces []policy.Namespace
+		var policies []networkingv1.NetworkPolicy
+		for _, n := range []*rclusterv3.Namespace{ns1InAlpha, ns2InAlpha, ns1InBravo, ns2InBravo} {
+			ns, err := RancherServer.DefaultClusterClient.Namespace.ByID(n.ID)
+			Expect(err).NotTo(HaveOccurred(), "while fetching namespace %v", n.Name)
+			namespaces = append(namespaces, policy.Namespace{Name: ns.Name, Labels: ns.Labels})
+			policies = append(policies, policy.ProjectIsolationPolicy(ns.Name, ns.ProjectID))
+		}
+
+		var pods []policy.Pod
+		for _, e := range endpoints {
+			pods = append(pods, e.PolicyPod())
+		}
+		expected, err := policy.NewEvaluator(namespaces, policies).Matrix(pods, policy.DefaultPort)
+		Expect(err).NotTo(HaveOccurred(), "while computing the expected matrix")
+
+		endpoints, err = RancherServer.ResolveEndpoints(endpoints)
+		Expect(err).NotTo(HaveOccurred(), "while resolving the addresses of the endpoints")
+
+		// Probe through every kind of address, and record them all
+		// before asserting, so that the report tells whether a breach
+		// is in the policies of the CNI or in service routing.
+		observed := map[framework.Via]policy.Matrix{}
+		var inconclusive []framework.ProbeResult
+		for _, via := range framework.Vias {
+			m, results := prober.ProbeMatrixVia(endpoints, via)
+			ConnectivityRecorder.Record(CurrentGinkgoTestDescription().FullTestText,
+				framework.NewConnectivity("project isolation via "+string(via), expected, results))
+			observed[via] = m
+			inconclusive = append(inconclusive, framework.Inconclusive(results)...)
+		}
+		Expect(inconclusive).To(BeEmpty(), "probes failed without reaching the network policies")
+		for _, via := range framework.Vias {
+			Expect(expected.Diff(observed[via])).To(BeEmpty(), "via %v, expected:\n%v\nobserved:\n%v", via, expected, observed[via])
+		}
 	})
 })
@@ -0,0 +1,118 @@
+package networkpolicy_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/test-network-policy/framework"
+	rmgmtv3 "github.com/rancher/types/client/management/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("RBACIsolation", func() {
+	var (
+		projAlpha, projBravo *rmgmtv3.Project
+		member               *framework.TestUser
+		memberServer         *framework.RancherServer
+		inAlpha, inBravo     framework.Endpoint
+	)
+
+	BeforeEach(func() {
+		requireRancher()
+
+		projAlpha, projBravo = nil, nil
+		var err error
+		for _, p := range []struct {
+			project  **rmgmtv3.Project
+			endpoint *framework.Endpoint
+			name     string
+		}{
+			{&projAlpha, &inAlpha, "alpha"},
+			{&projBravo, &inBravo, "bravo"},
+		} {
+			*p.project, err = RancherServer.CreateProject(nodeName("proj-rbac-" + p.name))
+			Expect(err).NotTo(HaveOccurred(), "while creating project %v", p.name)
+
+			ns := nodeName("ns-in-proj-rbac-" + p.name)
+			_, err = RancherServer.CreateNamespace(ns, (*p.project).ID, nil)
+			Expect(err).NotTo(HaveOccurred(), "while creating namespace %v", ns)
+
+			client, err := RancherServer.GetProjectClientByID((*p.project).ID)
+			Expect(err).NotTo(HaveOccurred(), "while creating client for project %v", p.name)
+			w, err := framework.CreateWorkload(client, framework.NewProbeWorkload("w-"+p.name, ns, nil))
+			Expect(err).NotTo(HaveOccurred(), "while creating workload in %v", ns)
+			*p.endpoint, err = framework.GetWorkloadEndpoint(client, w)
+			Expect(err).NotTo(HaveOccurred(), "while fetching endpoint of workload in %v", ns)
+		}
+
+		By("creating a member of proj-rbac-alpha", func() {
+			member, err = RancherServer.CreateUser(nodeName("test-network-policy-member"))
+			Expect(err).NotTo(HaveOccurred(), "while creating user")
+			_, err = RancherServer.BindProjectRole(member, projAlpha.ID, framework.ProjectMemberRole)
+			Expect(err).NotTo(HaveOccurred(), "while binding user to proj-rbac-alpha")
+
+			memberServer, err = RancherServer.LoginAs(member)
+			Expect(err).NotTo(HaveOccurred(), "while logging in as user")
+		})
+	})
+
+	AfterEach(func() {
+		if RancherServer == nil {
+			return
+		}
+		collectDiagnosticsOnFailure(projAlpha, projBravo)
+
+		if memberServer != nil {
+			Expect(memberServer.Close()).To(Succeed(), "while deleting the token of the user")
+			memberServer = nil
+		}
+		if member != nil {
+			Expect(RancherServer.DeleteUser(member)).To(Succeed(), "while deleting the user")
+			member = nil
+		}
+		By("deleting projects", func() {
+			for _, p := range []*rmgmtv3.Project{projAlpha, projBravo} {
+				if p != nil {
+					Expect(RancherServer.ManagementClient.Project.Delete(p)).To(Succeed(), "while deleting project %v", p.Name)
+				}
+			}
+		})
+	})
+
+	It("a project member should only reach the pods of its project", func() {
+		timeout := time.Duration(DefaultTimeout) * time.Second
+
+		By("execing into a pod of its project", func() {
+			// The role bindings take a while to reach the cluster.
+			Eventually(func() error {
+				_, err := memberServer.Exec(inAlpha.Namespace, inAlpha.Pod, inAlpha.Container, "hostname")
+				return err
+			}, timeout, time.Second).Should(Succeed(), "while execing into %v as a member", inAlpha)
+
+			output, err := memberServer.Exec(inAlpha.Namespace, inAlpha.Pod, inAlpha.Container, "hostname")
+			Expect(err).NotTo(HaveOccurred(), "while execing into %v as a member", inAlpha)
+			Expect(output).To(ContainSubstring(inAlpha.Pod))
+		})
+
+		By("listing the pods of its project", func() {
+			pods := &corev1.PodList{}
+			err := memberServer.ListObjects(framework.PodResource, inAlpha.Namespace, metav1.ListOptions{}, pods)
+			Expect(err).NotTo(HaveOccurred(), "while listing pods of %v as a member", inAlpha.Namespace)
+			Expect(pods.Items).NotTo(BeEmpty())
+		})
+
+		By("failing to exec into a pod of another project", func() {
+			_, err := memberServer.Exec(inBravo.Namespace, inBravo.Pod, inBravo.Container, "hostname")
+			Expect(framework.IsExecForbidden(err)).To(BeTrue(), "a member of proj-rbac-alpha could exec into %v: %v", inBravo, err)
+		})
+
+		By("failing to list the pods of another project", func() {
+			pods := &corev1.PodList{}
+			err := memberServer.ListObjects(framework.PodResource, inBravo.Namespace, metav1.ListOptions{}, pods)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue(), "a member of proj-rbac-alpha could list pods of %v: %v", inBravo.Namespace, err)
+		})
+	})
+})
@@ -0,0 +1,141 @@
+package networkpolicy_test
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/config"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/test-network-policy/framework"
+)
+
+// ScaleRecorder collects the results of the scale specs, which the file
+// reporters write and which are summarized at the end of the suite.
+var ScaleRecorder = &framework.ScaleRecorder{}
+
+// roundsTimeout returns the time given to the probes of n pairs to
+// converge: DefaultTimeout, plus the time a round of their probes can
+// take when all of them time out, since the prober only runs
+// Parallelism of them at once.
+func roundsTimeout(prober *framework.Prober, n int) time.Duration {
+	batches := (n + prober.Parallelism - 1) / prober.Parallelism
+	return time.Duration(DefaultTimeout)*time.Second + time.Duration(batches)*prober.Timeout
+}
+
+// The scale specs only run for the sizes listed in RANCHER_TEST_SCALE,
+// since building their topologies takes long.
+var _ = Describe("Scale", func() {
+	specs, err := framework.ScaleSpecsFromEnvVars()
+	if err != nil {
+		It("should parse RANCHER_TEST_SCALE", func() {
+			Fail(err.Error())
+		})
+		return
+	}
+
+	for _, spec := range specs {
+		spec := spec
+
+		Context(spec.String(), func() {
+			var scaleTopology *framework.Topology
+
+			BeforeEach(func() {
+				requireRancher()
+			})
+
+			AfterEach(func() {
+				if scaleTopology == nil {
+					return
+				}
+				if err := scaleTopology.Delete(); err != nil {
+					fmt.Fprintf(GinkgoWriter, "%v\n", err)
+				}
+				scaleTopology = nil
+			})
+
+			It("should isolate the projects and converge after a namespace is moved", func() {
+				prober := framework.NewProber(RancherServer)
+				rng := rand.New(rand.NewSource(config.GinkgoConfig.RandomSeed))
+				result := framework.ScaleResult{Spec: spec}
+				topologySpec := spec.Topology(nodeSuffix())
+
+				var endpoints []framework.Endpoint
+				By("building the topology", func() {
+					start := time.Now()
+					var err error
+					scaleTopology, err = framework.BuildTopology(RancherServer, topologySpec)
+					Expect(err).NotTo(HaveOccurred(), "while building the topology")
+					result.Creation = time.Since(start)
+
+					endpoints, err = scaleTopology.Endpoints()
+					Expect(err).NotTo(HaveOccurred(), "while fetching the endpoints")
+					result.Endpoints = len(endpoints)
+					result.Pairs = len(endpoints) * (len(endpoints) - 1)
+				})
+				// The result is recorded even when the probes fail, to
+				// report how long they took to converge when they did.
+				defer func() { ScaleRecorder.Record(result) }()
+
+				By("probing a sample of the pairs", func() {
+					var expectations []framework.Expectation
+					for _, pair := range framework.SamplePairs(endpoints, rng) {
+						expectations = append(expectations, framework.Expectation{
+							Pair:      pair,
+							Reachable: pair.From.Project == pair.To.Project,
+						})
+					}
+					result.Sampled = len(expectations)
+
+					samples := prober.WaitForConvergence(time.Now(), expectations, framework.DefaultConvergenceInterval, roundsTimeout(prober, len(expectations)))
+					for _, s := range samples {
+						Expect(s.Converged).To(BeTrue(), "%v is not in its expected state (%v)", s.Pair, s.Kind)
+					}
+
+					// The policies are only counted once the sampled pairs
+					// are isolated, when Rancher has reconciled them.
+					var err error
+					result.Policies, err = scaleTopology.CountNetworkPolicies()
+					Expect(err).NotTo(HaveOccurred(), "while counting the network policies")
+				})
+
+				if spec.Projects >= 2 {
+					By("moving a namespace of the first project into the second one", func() {
+						projectNames := topologySpec.ProjectNames()
+						moved := topologySpec.NamespaceNames(projectNames[0])[0]
+						target := scaleTopology.Project(projectNames[1])
+						project := func(e framework.Endpoint) string {
+							if e.Namespace == moved {
+								return target.ID
+							}
+							return e.Project
+						}
+
+						var expectations []framework.Expectation
+						for _, pair := range framework.SamplePairsWith(endpoints, moved, rng) {
+							expectations = append(expectations, framework.Expectation{
+								Pair:      pair,
+								Reachable: project(pair.From) == project(pair.To),
+							})
+						}
+
+						ns, err := RancherServer.DefaultClusterClient.Namespace.ByID(scaleTopology.Namespace(moved).ID)
+						Expect(err).NotTo(HaveOccurred(), "while fetching namespace %v", moved)
+
+						start := time.Now()
+						err = RancherServer.MoveNamespace(ns, target.ID)
+						Expect(err).NotTo(HaveOccurred(), "while moving namespace %v", moved)
+
+						samples := prober.WaitForConvergence(start, expectations, framework.DefaultConvergenceInterval, roundsTimeout(prober, len(expectations)))
+						ConvergenceRecorder.Record(samples...)
+						result.Convergence = framework.SummarizeConvergence(samples)
+						for _, s := range samples {
+							Expect(s.Converged).To(BeTrue(), "%v did not converge (%v after %v)", s.Pair, s.Kind, s.Duration)
+						}
+					})
+				}
+			})
+		})
+	}
+})
@@ -0,0 +1,99 @@
+package networkpolicy_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/rancher/test-network-policy/scenario"
+)
+
+var _ = Describe("Scenarios", func() {
+	scenarios, err := scenario.LoadDir("scenarios")
+	if err != nil {
+		It("should load the scenarios", func() {
+			Expect(err).NotTo(HaveOccurred(), "while loading scenarios")
+		})
+		return
+	}
+
+	placements, err := framework.PlacementsFromEnvVars()
+	if err != nil {
+		It("should parse the placements", func() {
+			Expect(err).NotTo(HaveOccurred(), "while parsing the placements")
+		})
+		return
+	}
+
+	for _, s := range scenarios {
+		for _, placement := range placements {
+			s, placement := s.WithSuffix(placementSuffix(placement)+nodeSuffix()), placement
+			Context(placementName(s.Name, placement), func() {
+				var runner *scenario.Runner
+
+				BeforeEach(func() {
+					if RancherServer != nil {
+						runner = scenario.NewRunner(RancherServer)
+					} else {
+						runner = scenario.NewKubeRunner(KubeCluster)
+					}
+					if s.ProjectIsolation && !runner.SupportsProjects() {
+						Skip("needs Rancher project isolation")
+					}
+					runner.Placement = placement
+					ok, err := runner.SupportsPlacement()
+					Expect(err).NotTo(HaveOccurred(), "while listing the schedulable nodes")
+					if !ok {
+						Skip(fmt.Sprintf("needs %v schedulable nodes", placement.MinNodes()))
+					}
+				})
+
+				AfterEach(func() {
+					collectDiagnosticsOnFailure(runner.Projects()...)
+
+					err := runner.Cleanup()
+					Expect(err).NotTo(HaveOccurred(), "while cleaning up scenario %v", s.Name)
+				})
+
+				It(s.String(), func() {
+					result, err := runner.Run(s)
+					Expect(err).NotTo(HaveOccurred(), "while running scenario %v", s.Name)
+					// Record every kind of address before asserting, so that
+					// the report tells which of them were breached.
+					for _, v := range result.Vias {
+						ConnectivityRecorder.Record(CurrentGinkgoTestDescription().FullTestText,
+							framework.NewConnectivity(placementName(s.Name+" via "+string(v.Via), placement), result.Expected, v.Probes))
+					}
+					for _, v := range result.Vias {
+						Expect(v.Inconclusive).To(BeEmpty(), "probes via %v failed without reaching the network policies", v.Via)
+						Expect(v.Mismatches).To(BeEmpty(), "via %v, expected:\n%v\nobserved:\n%v", v.Via, result.Expected, v.Observed)
+					}
+				})
+			})
+		}
+	}
+})
+
+// placementSuffix returns the suffix of the names of the fixtures of
+// scenarios run with the placement, which keeps them apart from the
+// fixtures of the other placements still being deleted.
+func placementSuffix(p framework.Placement) string {
+	switch p {
+	case framework.SameNode:
+		return "-sn"
+	case framework.CrossNode:
+		return "-xn"
+	default:
+		return ""
+	}
+}
+
+// placementName labels the name with the placement, unless the
+// workloads are left to the scheduler.
+func placementName(name string, p framework.Placement) string {
+	if p == framework.AnyNode {
+		return name
+	}
+	return name + " (" + string(p) + ")"
+}
@@ -12,10 +12,16 @@ import (
 )
 
 func GetWSURL(url, clusterID, podNS, podName, containerName, command string) string {
-	s := strings.Replace(url, "https", "wss", 1)
+	return GetKubeWSURL(url+"/k8s/clusters/"+clusterID, podNS, podName, containerName, command)
+}
+
+// GetKubeWSURL returns the websocket URL to exec the command in the
+// container through the Kubernetes API at host.
+func GetKubeWSURL(host, podNS, podName, containerName, command string) string {
+	s := strings.Replace(host, "http", "ws", 1)
 	fmtCmd := GetFormattedCommand(command)
-	wsURLTemplate := "%v/k8s/clusters/%v/api/v1/namespaces/%v/pods/%v/exec?container=%v&stdout=1&stdin=1&stderr=1&tty=0%v"
-	wsURL := fmt.Sprintf(wsURLTemplate, s, clusterID, podNS, podName, containerName, fmtCmd)
+	wsURLTemplate := "%v/api/v1/namespaces/%v/pods/%v/exec?container=%v&stdout=1&stdin=1&stderr=1&tty=0%v"
+	wsURL := fmt.Sprintf(wsURLTemplate, s, podNS, podName, containerName, fmtCmd)
 	return wsURL
 }
 
@@ -29,23 +35,46 @@ func GetFormattedCommand(command string) string {
 }
 
 func RunExecCommand(wsURL, username, password, token string) (string, error) {
-	var data []byte
-	var credentials string
+	var auth string
 
-	if username != "" && password != "" {
-		credentials = username + ":" + password
-	} else if token != "" {
-		credentials = token
+	if token != "" {
+		auth = "Bearer " + token
+	} else if username != "" && password != "" {
+		auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
 	} else {
 		return "", fmt.Errorf("login credentials not provided")
 	}
-	h := http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))}}
+	h := http.Header{"Authorization": {auth}}
+
+	return RunExecCommandWithHeader(wsURL, h, &tls.Config{InsecureSkipVerify: true})
+}
+
+// HandshakeError is the error of an exec websocket handshake which the
+// server rejected with an HTTP status, such as 403 when the credentials
+// are not allowed to exec into the pod.
+type HandshakeError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("%v: %v", websocket.ErrBadHandshake, e.Status)
+}
+
+// RunExecCommandWithHeader runs the exec websocket request with the
+// header, which carries the credentials, and returns its output. A
+// rejected handshake returns a *HandshakeError.
+func RunExecCommandWithHeader(wsURL string, h http.Header, tlsConfig *tls.Config) (string, error) {
+	var data []byte
 
 	d := &websocket.Dialer{
 		HandshakeTimeout: 45 * time.Second,
-		TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig:  tlsConfig,
+	}
+	c, resp, err := d.Dial(wsURL, h)
+	if err == websocket.ErrBadHandshake && resp != nil {
+		return "", &HandshakeError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
-	c, _, err := d.Dial(wsURL, h)
 	if err != nil {
 		return "", err
 	}
@@ -1,8 +1,11 @@
 package utils
 
 import (
-	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestGetWSURL(t *testing.T) {
@@ -31,3 +34,24 @@ func TestGetFormattedCommand(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetKubeWSURL(t *testing.T) {
+	expected := "ws://127.0.0.1:8080/api/v1/namespaces/ns1/pods/w1-abc/exec?container=w1&stdout=1&stdin=1&stderr=1&tty=0&command=hostname"
+	actual := GetKubeWSURL("http://127.0.0.1:8080", "ns1", "w1-abc", "w1", "hostname")
+	if actual != expected {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestRunExecCommandWithHeaderForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := RunExecCommandWithHeader(GetKubeWSURL(server.URL, "ns1", "w1-abc", "w1", "hostname"), http.Header{}, nil)
+	handshakeErr, ok := err.(*HandshakeError)
+	if !ok || handshakeErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a forbidden handshake error, got %#v", err)
+	}
+}
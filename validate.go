@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rancher/test-network-policy/framework"
+	"github.com/rancher/test-network-policy/scenario"
+	"github.com/spf13/pflag"
+)
+
+func validateCommand(args []string) error {
+	flags := pflag.NewFlagSet("validate", pflag.ExitOnError)
+	scenarios := flags.String("scenarios", "suites/networkpolicy/scenarios", "directory of the scenarios to validate, empty to skip")
+	offline := flags.Bool("offline", false, "only validate the scenarios, without connecting to the Rancher server")
+	flags.Parse(args)
+
+	if *scenarios != "" {
+		loaded, err := scenario.LoadDir(*scenarios)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("scenarios: %v valid in %v\n", len(loaded), *scenarios)
+	}
+
+	if *offline {
+		return nil
+	}
+
+	rs, err := framework.NewRancherServerFromEnvVars()
+	if err != nil {
+		return err
+	}
+	defer rs.Close()
+	fmt.Printf("server:    %v\n", rs.URL)
+	fmt.Printf("cluster:   %v (%v)\n", rs.DefaultCluster.Name, rs.DefaultCluster.ID)
+
+	leftovers, err := rs.ListTestProjects()
+	if err != nil {
+		return fmt.Errorf("error accessing projects of cluster %v: %v", rs.DefaultCluster.Name, err)
+	}
+	fmt.Println("credentials: ok")
+	if len(leftovers) > 0 {
+		fmt.Printf("warning: %v projects left over by previous runs, see the cleanup command\n", len(leftovers))
+	}
+	return nil
+}